@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// configActions maps the actions of 'startainer config' to their implementation
+var configActions = map[string]func(containerManagerCmd string, args []string){
+	"add":    configAdd,
+	"edit":   configEdit,
+	"path":   configPath,
+	"rename": configRename,
+	"rm":     configRemove,
+}
+
+/*
+ConfigCommand implements 'startainer config add|edit|rm|rename|path', which manage the definitions within the configuration file.
+The file is edited as text, so that its comments and the ordering of the definitions are preserved.
+*/
+func ConfigCommand(containerManagerCmd string, args []string) {
+	if len(args) == 0 || configActions[args[0]] == nil {
+		fmt.Fprintf(os.Stderr, "Usage:\n  %[1]s config add <name> [-image <image>] [-message <text>] [-pull <policy>] -- <parameters for 'run'>\n  %[1]s config edit <name>\n  %[1]s config rm [-force] <name>\n  %[1]s config rename [-force] <name> <new name>\n  %[1]s config path\n", os.Args[0])
+		log.Fatal("Specify the action: add, edit, rm, rename or path")
+	}
+	configActions[args[0]](containerManagerCmd, args[1:])
+}
+
+// configPath implements 'startainer config path', which prints out the path of the configuration file in use
+func configPath(containerManagerCmd string, args []string) {
+	fmt.Println(viper.ConfigFileUsed())
+}
+
+/*
+configAdd implements 'startainer config add <name> [-image <image>] -- <parameters for run>', which appends a container
+definition to the configuration file. The image is appended to the parameters of 'run', unless they already contain it.
+*/
+func configAdd(containerManagerCmd string, args []string) {
+	fs := newCommandFlagSet("config add", "<name> [-image <image>] [-message <text>] [-pull <policy>] -- <parameters for 'run'>")
+	flagImage := fs.String("image", "", "Image of the container, appended to the parameters of 'run'")
+	flagMessage := fs.String("message", "", "Message printed out when the container is started")
+	flagPull := fs.String("pull", "", "Pull policy of the image: always, missing, never, daily or weekly")
+	names, run_args := parseCommandArgs(fs, args)
+	if len(names) != 1 {
+		fs.Usage()
+		log.Fatal("Specify the name of the new definition")
+	}
+	name := names[0]
+	if err := ValidDefinitionName(name); err != nil {
+		log.Fatal(err)
+	}
+	if *flagPull != "" && !IsIn(*flagPull, []string{"always", "missing", "never", "daily", "weekly"}) {
+		log.Fatalf("Invalid pull policy '%s': use always, missing, never, daily or weekly", *flagPull)
+	}
+	spec, err := parseRunArgs(run_args)
+	if err != nil || spec.Image == "" {
+		if *flagImage == "" {
+			fs.Usage()
+			log.Fatal("Specify the image with -image, or at the end of the parameters for 'run'")
+		}
+		if spec, err = parseRunArgs(append(run_args, *flagImage)); err != nil {
+			log.Fatalf("Impossible to analyze the parameters for 'run'. %s", err)
+		}
+	} else if *flagImage != "" && spec.Image != *flagImage {
+		log.Fatalf("The parameters for 'run' already contain the image '%s', which differs from '%s'", spec.Image, *flagImage)
+	}
+
+	def := newYamlDefinition(name, "")
+	def.Set("image", spec.Image)
+	def.Set("pull", *flagPull)
+	def.Set("message", *flagMessage)
+	def.Set("run", spec.Args())
+	if err := ValidateDefinitionText(name, def.String()); err != nil {
+		log.Fatal(err)
+	}
+	if dryRun {
+		explain("would append the definition to the configuration file '%s':\n%s", viper.ConfigFileUsed(), def.String())
+		return
+	}
+	if err := AppendDefinition(def); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Definition '%s' added to configuration file '%s'", name, viper.ConfigFileUsed())
+}
+
+// configRemove implements 'startainer config rm [-force] <name>', which removes a definition, and the comments preceding it, from the configuration file
+func configRemove(containerManagerCmd string, args []string) {
+	fs := newCommandFlagSet("config rm", "[-force] <name>")
+	flagForce := fs.Bool("force", false, "Do not ask for confirmation before removing the definition")
+	names, _ := parseCommandArgs(fs, args)
+	if len(names) != 1 {
+		fs.Usage()
+		log.Fatal("Specify the name of the definition to remove")
+	}
+	name := names[0]
+	if err := checkDefinition(name); err != nil {
+		log.Fatal(err)
+	}
+	lines, err := readConfigLines()
+	if err != nil {
+		log.Fatal(err)
+	}
+	comment, _, end, err := definitionBlock(lines, name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !*flagForce && !Confirm("Remove the definition '"+name+"' from the configuration file? Its containers are not affected") {
+		log.Printf("Definition '%s' not removed", name)
+		return
+	}
+	lines = append(lines[:comment], lines[end:]...)
+	// do not leave two blank lines where the definition was
+	if comment > 0 && comment < len(lines) && strings.TrimSpace(lines[comment-1]) == "" && strings.TrimSpace(lines[comment]) == "" {
+		lines = append(lines[:comment], lines[comment+1:]...)
+	}
+	if err := WriteConfigLines(lines); err != nil {
+		log.Fatal(err)
+	}
+	warnGroupReferences(name)
+	log.Printf("Definition '%s' removed from configuration file '%s'", name, viper.ConfigFileUsed())
+}
+
+/*
+configRename implements 'startainer config rename <name> <new name>'. As the containers of a definition are found by its
+name, the renaming is refused while a container of the definition exists, unless -force is provided.
+*/
+func configRename(containerManagerCmd string, args []string) {
+	fs := newCommandFlagSet("config rename", "[-force] <name> <new name>")
+	flagForce := fs.Bool("force", false, "Rename the definition even if its container exists")
+	names, _ := parseCommandArgs(fs, args)
+	if len(names) != 2 {
+		fs.Usage()
+		log.Fatal("Specify the name of the definition and its new name")
+	}
+	name, new_name := names[0], names[1]
+	if err := ValidDefinitionName(new_name); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkDefinition(name); err != nil {
+		log.Fatal(err)
+	}
+	lines, err := readConfigLines()
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, start, _, err := definitionBlock(lines, name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if ConfigType(name) == CONFTYPECONTAINER && !*flagForce {
+		if status, err := ContainerStatus(containerManagerCmd, name, false); err == nil && status != MISSING {
+			log.Fatalf("The container '%s' of '%s' exists, and would not be found by the new name. Remove it first with 'startainer rm %s', or use -force", ContainerName(containerManagerCmd, name), name, name)
+		}
+	}
+	// only the key is replaced, keeping a comment on the same line
+	key := topLevelKeyRegex.FindStringSubmatch(lines[start])[1]
+	lines[start] = new_name + strings.TrimPrefix(lines[start], key)
+	if err := WriteConfigLines(lines); err != nil {
+		log.Fatal(err)
+	}
+	warnGroupReferences(name)
+	log.Printf("Definition '%s' renamed to '%s'", name, new_name)
+}
+
+/*
+configEdit implements 'startainer config edit <name>', which opens only the given definition within the editor set by
+$VISUAL or $EDITOR. Once the editor is closed, the definition is validated and replaced within the configuration file.
+*/
+func configEdit(containerManagerCmd string, args []string) {
+	fs := newCommandFlagSet("config edit", "<name>")
+	names, _ := parseCommandArgs(fs, args)
+	if len(names) != 1 {
+		fs.Usage()
+		log.Fatal("Specify the name of the definition to edit")
+	}
+	name := names[0]
+	if err := checkDefinition(name); err != nil {
+		log.Fatal(err)
+	}
+	lines, err := readConfigLines()
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, start, end, err := definitionBlock(lines, name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	original := strings.Join(lines[start:end], "\n") + "\n"
+
+	tmp, err := ioutil.TempFile("", "startainer-"+name+"-*.yaml")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.WriteString(original)
+	tmp.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var edited string
+	for {
+		if err := openEditor(tmp.Name()); err != nil {
+			log.Fatal(err)
+		}
+		content, err := ioutil.ReadFile(tmp.Name())
+		if err != nil {
+			log.Fatal(err)
+		}
+		edited = string(content)
+		if edited == original {
+			log.Printf("Definition '%s' not changed", name)
+			return
+		}
+		err = ValidateDefinitionText(name, edited)
+		if err == nil {
+			break
+		}
+		log.Print(red(err))
+		if !Confirm("Edit the definition again?") {
+			log.Printf("Definition '%s' not changed", name)
+			return
+		}
+	}
+	edited_lines := strings.Split(strings.TrimRight(edited, "\n"), "\n")
+	lines = append(lines[:start], append(edited_lines, lines[end:]...)...)
+	if err := WriteConfigLines(lines); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Definition '%s' updated within configuration file '%s'", name, viper.ConfigFileUsed())
+}
+
+// openEditor opens a file within the editor set by $VISUAL or $EDITOR, e.g. 'code --wait', and waits for it to be closed
+func openEditor(file string) error {
+	editor := strings.Fields(os.Getenv("VISUAL"))
+	if len(editor) == 0 {
+		editor = strings.Fields(os.Getenv("EDITOR"))
+	}
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+		if runtime.GOOS == "windows" {
+			editor = []string{"notepad"}
+		}
+	}
+	cmd := exec.Command(editor[0], append(editor[1:], file)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("the editor '%s' failed. Set another one with the EDITOR environment variable. %s", strings.Join(editor, " "), err)
+	}
+	return nil
+}
+
+// checkDefinition returns an error if the top-level key of the configuration file is not a container or compose definition, e.g. 'settings'
+func checkDefinition(name string) error {
+	if strings.ToLower(name) == "settings" {
+		return fmt.Errorf("'settings' is not a definition: edit the configuration file '%s' to change the settings", viper.ConfigFileUsed())
+	}
+	if ConfigType(name) == CONFTYPEUNKNOWN {
+		return fmt.Errorf("'%s' is not a container or compose definition within the configuration file '%s'", name, viper.ConfigFileUsed())
+	}
+	return nil
+}
+
+// warnGroupReferences warns about the groups within 'settings.groups' which still reference a definition removed or renamed
+func warnGroupReferences(name string) {
+	for group := range viper.GetStringMap("settings.groups") {
+		if IsIn(name, viper.GetStringSlice("settings.groups."+group)) {
+			log.Printf("Warning: the group '%s' references '%s' within the settings: update it", group, name)
+		}
+	}
+}
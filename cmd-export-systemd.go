@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+const (
+	EXPORTSYSTEMD string = "systemd" // systemd service unit executing '<runtime> run'
+	EXPORTQUADLET string = "quadlet" // podman Quadlet '.container' file, translated into a service by podman
+)
+
+// unitName returns the name of the systemd service generated for a definition
+func unitName(definition string) string {
+	return "startainer-" + definition
+}
+
+// unitInstallDir returns the folder where systemd, or podman for Quadlet files, looks for the units of the user
+func unitInstallDir(format string) (string, error) {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if base == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".config")
+	}
+	if format == EXPORTQUADLET {
+		return filepath.Join(base, "containers", "systemd"), nil
+	}
+	return filepath.Join(base, "systemd", "user"), nil
+}
+
+// systemdQuote quotes a value for the command lines and the assignments of systemd unit files
+func systemdQuote(value string) string {
+	value = strings.ReplaceAll(value, "%", "%%")
+	if value != "" && !strings.ContainsAny(value, " \t\"'\\$;") {
+		return value
+	}
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", "$$").Replace(value)
+	return `"` + value + `"`
+}
+
+// systemdRestart translates the '--restart' policy of docker into the 'Restart=' setting of systemd
+func systemdRestart(policy string) string {
+	switch strings.SplitN(policy, ":", 2)[0] {
+	case "always", "unless-stopped":
+		return "always"
+	case "no":
+		return "no"
+	default:
+		return "on-failure"
+	}
+}
+
+/*
+unitRunSpec prepares the 'run' configuration of a definition to be executed by systemd:
+paths are expanded, the container runs in foreground, and the restart policy is handled by systemd.
+Environment variables read from the environment of startainer are reported, as they are not available to systemd.
+*/
+func unitRunSpec(definition string) (spec runSpec, restart string, err error) {
+	if ConfigType(definition) != CONFTYPECONTAINER {
+		return spec, "", fmt.Errorf("'%s' is not a container definition, only container definitions can be exported", definition)
+	}
+	parsed, err := parseRunArgs(RunConfig(definition))
+	if err != nil {
+		return spec, "", err
+	}
+	spec.Image, spec.Command = parsed.Image, parsed.Command
+	restart = "on-failure"
+	name_set := false
+	for _, opt := range parsed.Options {
+		switch name := canonicalRunFlag(opt.Flag); {
+		case !opt.HasValue:
+			// systemd attaches to the container and removes it when stopped: drop detach, terminal and removal flags
+			dropped := []string{"--detach", "--tty", "--interactive", "--rm"}
+			if len(opt.names()) == 1 && IsIn(name, dropped) {
+				continue
+			} else if len(opt.names()) > 1 {
+				// combined short flags, e.g. '-dP'
+				flag := "-"
+				for _, c := range opt.Flag[1:] {
+					if !IsIn(canonicalRunFlag("-"+string(c)), dropped) {
+						flag += string(c)
+					}
+				}
+				if flag == "-" {
+					continue
+				}
+				opt.Flag = flag
+			}
+		case name == "--restart":
+			restart = systemdRestart(opt.Value)
+			continue
+		case name == "--name":
+			name_set = true
+		case name == "--volume":
+			if parts := strings.SplitN(opt.Value, ":", 2); len(parts) == 2 {
+				if strings.HasPrefix(parts[0], ".") {
+					log.Printf("Warning: the volume '%s' of '%s' is relative to the current folder, which is expanded at export time", opt.Value, definition)
+				}
+				opt.Value = expandHostPath(parts[0]) + ":" + parts[1]
+			}
+		case name == "--mount":
+			items := strings.Split(opt.Value, ",")
+			for i, item := range items {
+				if kv := strings.SplitN(item, "=", 2); len(kv) == 2 && IsIn(kv[0], []string{"source", "src"}) {
+					items[i] = kv[0] + "=" + expandHostPath(kv[1])
+				}
+			}
+			opt.Value = strings.Join(items, ",")
+		case name == "--env-file":
+			opt.Value = expandHostPath(opt.Value)
+		case name == "--env" && !strings.Contains(opt.Value, "="):
+			log.Printf("Warning: '%s' reads the variable '%s' from the environment, which is not available to systemd. Provide it within an env file", definition, opt.Value)
+		}
+		spec.Options = append(spec.Options, opt)
+	}
+	if !name_set {
+		// the container is named as the definition, so that startainer keeps tracking it
+		spec.Options = append([]runOption{{Flag: "--name", Value: definition, HasValue: true}}, spec.Options...)
+	}
+	spec.Options = append([]runOption{{Flag: "--label", Value: DEFINITIONLABEL + "=" + definition, HasValue: true}}, spec.Options...)
+	return spec, restart, nil
+}
+
+// systemdUnit generates a systemd service unit executing the container of a definition in foreground
+func systemdUnit(containerManagerCmd string, definition string) (string, error) {
+	spec, restart, err := unitRunSpec(definition)
+	if err != nil {
+		return "", err
+	}
+	// systemd needs the absolute path of the executable
+	runtime_path, err := exec.LookPath(containerManagerCmd)
+	if err != nil {
+		runtime_path = containerManagerCmd
+	}
+	containerName := spec.Value("--name")
+	var exec_start []string
+	for _, arg := range append([]string{runtime_path, "run", "--rm"}, spec.Args()...) {
+		exec_start = append(exec_start, systemdQuote(arg))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# generated by startainer %s from the definition '%s'\n", VERSION, definition)
+	fmt.Fprintf(&b, "[Unit]\nDescription=startainer definition '%s'\nWants=network-online.target\nAfter=network-online.target\n\n", definition)
+	b.WriteString("[Service]\n")
+	fmt.Fprintf(&b, "Restart=%s\n", restart)
+	b.WriteString("TimeoutStopSec=70\n")
+	// remove leftovers of previous executions, '-' ignores failures
+	fmt.Fprintf(&b, "ExecStartPre=-%s rm -f %s\n", systemdQuote(runtime_path), systemdQuote(containerName))
+	fmt.Fprintf(&b, "ExecStart=%s \\\n    %s\n", strings.Join(exec_start[:3], " "), strings.Join(exec_start[3:], " \\\n    "))
+	fmt.Fprintf(&b, "ExecStop=%s stop %s\n\n", systemdQuote(runtime_path), systemdQuote(containerName))
+	b.WriteString("[Install]\nWantedBy=default.target\n")
+	return b.String(), nil
+}
+
+// quadletKeys maps the flags of 'podman run' to the keys of the [Container] section of Quadlet files
+var quadletKeys = map[string]string{
+	"--name": "ContainerName", "--publish": "PublishPort", "--volume": "Volume", "--mount": "Mount",
+	"--env": "Environment", "--env-file": "EnvironmentFile", "--network": "Network", "--workdir": "WorkingDir",
+	"--user": "User", "--hostname": "HostName", "--label": "Label", "--cap-add": "AddCapability",
+	"--cap-drop": "DropCapability", "--device": "AddDevice", "--tmpfs": "Tmpfs", "--expose": "ExposeHostPort",
+	"--entrypoint": "Entrypoint", "--health-cmd": "HealthCmd", "--health-interval": "HealthInterval",
+	"--health-retries": "HealthRetries", "--health-timeout": "HealthTimeout", "--health-start-period": "HealthStartPeriod",
+	"--add-host": "AddHost", "--dns": "DNS", "--pull": "Pull", "--security-opt": "SecurityLabelType",
+	"--sysctl": "Sysctl", "--ulimit": "Ulimit", "--shm-size": "ShmSize", "--group-add": "GroupAdd",
+}
+
+// quadletFile generates a podman Quadlet '.container' file for a definition.
+// Flags without a Quadlet counterpart are provided with 'PodmanArgs='.
+func quadletFile(containerManagerCmd string, definition string) (string, error) {
+	spec, restart, err := unitRunSpec(definition)
+	if err != nil {
+		return "", err
+	}
+	if !strings.Contains(filepath.Base(containerManagerCmd), "podman") {
+		log.Printf("Warning: Quadlet files are executed by podman, while the runtime is '%s'", containerManagerCmd)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# generated by startainer %s from the definition '%s'\n", VERSION, definition)
+	fmt.Fprintf(&b, "[Unit]\nDescription=startainer definition '%s'\n\n", definition)
+	b.WriteString("[Container]\n")
+	fmt.Fprintf(&b, "Image=%s\n", spec.Image)
+	var podman_args []string
+	for _, opt := range spec.Options {
+		name := canonicalRunFlag(opt.Flag)
+		key, ok := quadletKeys[name]
+		if name == "--security-opt" && !strings.HasPrefix(opt.Value, "label=type:") {
+			ok = false
+		}
+		switch {
+		case !opt.HasValue && IsIn("--read-only", opt.names()) && len(opt.names()) == 1:
+			b.WriteString("ReadOnly=true\n")
+		case !opt.HasValue || !ok:
+			podman_args = append(podman_args, systemdQuote(opt.arg()))
+		case key == "SecurityLabelType":
+			fmt.Fprintf(&b, "%s=%s\n", key, strings.TrimPrefix(opt.Value, "label=type:"))
+		default:
+			fmt.Fprintf(&b, "%s=%s\n", key, systemdQuote(opt.Value))
+		}
+	}
+	if len(podman_args) > 0 {
+		log.Printf("The following configurations of '%s' have no Quadlet counterpart, and are provided with 'PodmanArgs':\n  %s", definition, strings.Join(podman_args, "\n  "))
+		fmt.Fprintf(&b, "PodmanArgs=%s\n", strings.Join(podman_args, " "))
+	}
+	if len(spec.Command) > 0 {
+		var command []string
+		for _, arg := range spec.Command {
+			command = append(command, systemdQuote(arg))
+		}
+		fmt.Fprintf(&b, "Exec=%s\n", strings.Join(command, " "))
+	}
+	fmt.Fprintf(&b, "\n[Service]\nRestart=%s\nTimeoutStopSec=70\n\n", restart)
+	b.WriteString("[Install]\nWantedBy=default.target\n")
+	return b.String(), nil
+}
+
+/*
+exportUnits implements 'startainer export systemd|quadlet [-o <file>|-install] <definition|@group> ...'.
+With -install, the files are written into the folder of the user units, and the commands enabling them are printed out.
+*/
+func exportUnits(containerManagerCmd string, format string, args []string) {
+	fs := newCommandFlagSet("export "+format, "[-o <file> | -install] <definition|@group> ...")
+	flagOutput := fs.String("o", "", "Write the unit into this file, instead of printing it out. Only valid for a single definition")
+	flagInstall := fs.Bool("install", false, "Write the units into the folder of the systemd units of the user")
+	names, _ := parseCommandArgs(fs, args)
+	if len(names) == 0 {
+		fs.Usage()
+		log.Fatal("Specify at least one definition or group")
+	}
+	definitions, err := ResolveDefinitions(names, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *flagOutput != "" && (*flagInstall || len(definitions) > 1) {
+		log.Fatal("-o can be used only with a single definition, and not together with -install")
+	}
+
+	generate, extension := systemdUnit, ".service"
+	if format == EXPORTQUADLET {
+		generate, extension = quadletFile, ".container"
+	}
+	var install_dir string
+	if *flagInstall {
+		if install_dir, err = unitInstallDir(format); err != nil {
+			log.Fatal(err)
+		}
+		if !dryRun {
+			if err := os.MkdirAll(install_dir, 0755); err != nil {
+				log.Fatalf("Impossible to create folder '%s'. %s", install_dir, err)
+			}
+		}
+	}
+	var units []string
+	for i, definition := range definitions {
+		content, err := generate(containerManagerCmd, definition)
+		if err != nil {
+			log.Fatalf("Impossible to export '%s'. %s", definition, err)
+		}
+		units = append(units, unitName(definition)+".service")
+		switch {
+		case *flagInstall:
+			unit_file := filepath.Join(install_dir, unitName(definition)+extension)
+			if dryRun {
+				explain("would write the file '%s'", unit_file)
+			} else if err := ioutil.WriteFile(unit_file, []byte(content), 0644); err != nil {
+				log.Fatalf("Impossible to write file '%s'. %s", unit_file, err)
+			} else {
+				log.Printf("Unit of '%s' installed as '%s'", definition, unit_file)
+			}
+		case *flagOutput != "":
+			writeExport(content, *flagOutput)
+		default:
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Print(content)
+		}
+	}
+	if *flagInstall {
+		if format == EXPORTQUADLET {
+			// services generated from Quadlet files cannot be enabled, they are started at boot by their [Install] section
+			log.Printf("Load and start the units with:\n  systemctl --user daemon-reload\n  systemctl --user start %s", strings.Join(units, " "))
+		} else {
+			log.Printf("Load, enable and start the units with:\n  systemctl --user daemon-reload\n  systemctl --user enable --now %s", strings.Join(units, " "))
+		}
+		log.Print("To start them at boot, without the user logging in, execute once: loginctl enable-linger")
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+/*
+ExportCommand implements 'startainer export <format> ...', which translates container definitions into other formats:
+  - compose: a compose file with one service per definition
+  - systemd: a systemd service unit per definition
+  - quadlet: a podman Quadlet '.container' file per definition
+*/
+func ExportCommand(containerManagerCmd string, args []string) {
+	if len(args) == 0 {
+		log.Fatal("Specify the export format: compose, systemd or quadlet")
+	}
+	switch args[0] {
+	case "compose":
+		exportCompose(args[1:])
+	case EXPORTSYSTEMD, EXPORTQUADLET:
+		exportUnits(containerManagerCmd, args[0], args[1:])
+	default:
+		log.Fatalf("Unknown export format '%s'. Valid formats are: compose, systemd, quadlet", args[0])
+	}
+}
+
+// writeExport writes the exported content into a file, or to stdout if no file is provided
+func writeExport(content string, outputFile string) {
+	if outputFile == "" {
+		fmt.Print(content)
+		return
+	}
+	outputFile, _ = ExpandPath(outputFile)
+	if dryRun {
+		explain("would write the file '%s'", outputFile)
+		return
+	}
+	if err := ioutil.WriteFile(outputFile, []byte(content), 0644); err != nil {
+		log.Fatalf("Impossible to write file '%s'. %s", outputFile, err)
+	}
+	log.Printf("File '%s' written", outputFile)
+}
+
+// exportCompose implements 'startainer export compose [-o <file>] <definition|@group> ...'
+func exportCompose(args []string) {
+	fs := newCommandFlagSet("export compose", "[-o <file>] <definition|@group> ...")
+	flagOutput := fs.String("o", "", "Write the compose file into this file, instead of printing it out")
+	names, _ := parseCommandArgs(fs, args)
+	if len(names) == 0 {
+		fs.Usage()
+		log.Fatal("Specify at least one definition or group")
+	}
+	definitions, err := ResolveDefinitions(names, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	project := newYamlMap()
+	services := newYamlMap()
+	volumes := newYamlMap()
+	networks := newYamlMap()
+	for _, definition := range definitions {
+		if ConfigType(definition) != CONFTYPECONTAINER {
+			log.Fatalf("'%s' is not a container definition, only container definitions can be exported", definition)
+		}
+		service, unmapped, err := composeService(definition, volumes, networks)
+		if err != nil {
+			log.Fatalf("Impossible to export '%s'. %s", definition, err)
+		}
+		if len(unmapped) > 0 {
+			log.Printf("Warning: the following configurations of '%s' could not be mapped to compose, and were skipped:\n  %s", definition, strings.Join(unmapped, "\n  "))
+		}
+		services.Set(definition, service)
+	}
+	project.Set("services", services)
+	if len(volumes.keys) > 0 {
+		project.Set("volumes", volumes)
+	}
+	if len(networks.keys) > 0 {
+		project.Set("networks", networks)
+	}
+	writeExport(project.String(), *flagOutput)
+}
+
+// composeEscape escapes the '$' characters, which compose would otherwise interpolate as variables
+func composeEscape(values ...string) []string {
+	for i, value := range values {
+		values[i] = strings.ReplaceAll(value, "$", "$$")
+	}
+	return values
+}
+
+// expandHostPath expands ~ and . at the beginning of a host path, as done by ContainerRun for volumes
+func expandHostPath(path string) string {
+	if strings.HasPrefix(path, "~") || strings.HasPrefix(path, ".") {
+		cwd, _ := os.Getwd()
+		path = pathRelativeTo(path, cwd)
+	}
+	return path
+}
+
+/*
+composeService translates the 'run' configuration of a container definition into a compose service.
+Named volumes and networks used by the service are added to 'volumes' and 'networks', the top-level sections of the compose file.
+The configurations which could not be mapped are returned as well.
+*/
+func composeService(definition string, volumes *yamlMap, networks *yamlMap) (service *yamlMap, unmapped []string, err error) {
+	spec, err := parseRunArgs(RunConfig(definition))
+	if err != nil {
+		return nil, nil, err
+	}
+	service = newYamlMap()
+	service.Set("image", spec.Image)
+	if HasBuild(definition) {
+		if conf, err := readBuildConfig(definition); err != nil {
+			unmapped = append(unmapped, fmt.Sprintf("build: %s", err))
+		} else {
+			build := newYamlMap()
+			build.Set("context", conf.context)
+			if dockerfile, err := filepath.Rel(conf.context, conf.dockerfile); err == nil && dockerfile != "Dockerfile" {
+				build.Set("dockerfile", dockerfile)
+			}
+			build.Set("args", composeEscape(conf.args...))
+			build.Set("target", conf.target)
+			service.Set("build", build)
+		}
+	}
+
+	// the label allows startainer to keep tracking the container created by compose
+	service.Append("labels", DEFINITIONLABEL+"="+definition)
+	healthcheck := newYamlMap()
+	logging := newYamlMap()
+	for _, opt := range spec.Options {
+		name := canonicalRunFlag(opt.Flag)
+		value := composeEscape(opt.Value)[0]
+		switch {
+		case !opt.HasValue:
+			for _, name := range opt.names() {
+				switch name {
+				case "--detach":
+					// compose services are started detached with 'compose up -d'
+				case "--interactive":
+					service.Set("stdin_open", true)
+				case "--tty":
+					service.Set("tty", true)
+				case "--privileged", "--init", "--read-only":
+					service.Set(strings.ReplaceAll(strings.TrimPrefix(name, "--"), "-", "_"), true)
+				default:
+					unmapped = append(unmapped, opt.arg())
+				}
+			}
+		case name == "--name":
+			service.Set("container_name", value)
+		case name == "--publish":
+			service.Append("ports", value)
+		case name == "--volume":
+			parts := strings.SplitN(opt.Value, ":", 2)
+			if len(parts) == 2 && !strings.ContainsAny(parts[0], `/\`) && !strings.HasPrefix(parts[0], "~") && !strings.HasPrefix(parts[0], ".") {
+				// named volume, created outside of compose: keep its name, rather than prefixing it with the project name
+				volume := newYamlMap()
+				volume.Set("name", parts[0])
+				volumes.Set(parts[0], volume)
+			} else if len(parts) == 2 {
+				parts[0] = expandHostPath(parts[0])
+			}
+			service.Append("volumes", composeEscape(strings.Join(parts, ":"))...)
+		case name == "--mount":
+			// translated into the short syntax, which can be listed together with the volumes
+			mount := map[string]string{"type": "volume"}
+			for _, item := range strings.Split(opt.Value, ",") {
+				kv := strings.SplitN(item, "=", 2)
+				switch {
+				case IsIn(kv[0], []string{"readonly", "ro"}) && (len(kv) == 1 || kv[1] == "true" || kv[1] == "1"):
+					mount["readonly"] = ":ro"
+				case len(kv) == 2 && kv[0] == "type":
+					mount["type"] = kv[1]
+				case len(kv) == 2 && IsIn(kv[0], []string{"source", "src"}):
+					mount["source"] = kv[1]
+				case len(kv) == 2 && IsIn(kv[0], []string{"target", "destination", "dst"}):
+					mount["target"] = kv[1]
+				default:
+					unmapped = append(unmapped, fmt.Sprintf("%s (option '%s')", opt.arg(), item))
+				}
+			}
+			switch mount["type"] {
+			case "tmpfs":
+				service.Append("tmpfs", composeEscape(mount["target"])...)
+			case "bind", "volume":
+				source := mount["source"]
+				if mount["type"] == "bind" {
+					source = expandHostPath(source)
+				} else if source != "" {
+					volume := newYamlMap()
+					volume.Set("name", source)
+					volumes.Set(source, volume)
+				}
+				if source != "" {
+					source += ":"
+				}
+				service.Append("volumes", composeEscape(source+mount["target"]+mount["readonly"])...)
+			default:
+				unmapped = append(unmapped, opt.arg())
+			}
+		case name == "--env":
+			service.Append("environment", value)
+		case name == "--env-file":
+			service.Append("env_file", composeEscape(expandHostPath(opt.Value))...)
+		case name == "--network":
+			if IsIn(opt.Value, []string{"host", "none", "bridge"}) || strings.HasPrefix(opt.Value, "container:") {
+				service.Set("network_mode", value)
+			} else {
+				// networks used by 'docker run' must exist already
+				network := newYamlMap()
+				network.Set("name", value)
+				network.Set("external", true)
+				networks.Set(opt.Value, network)
+				service.Append("networks", opt.Value)
+			}
+		case name == "--stop-timeout":
+			service.Set("stop_grace_period", value+"s")
+		case name == "--memory":
+			service.Set("mem_limit", value)
+		case name == "--pull":
+			service.Set("pull_policy", value)
+		case IsIn(name, []string{"--restart", "--workdir", "--user", "--hostname", "--entrypoint", "--platform", "--shm-size", "--cpus", "--stop-signal", "--ipc", "--pid", "--domainname", "--mac-address"}):
+			service.Set(strings.ReplaceAll(strings.TrimPrefix(name, "--"), "-", "_"), value)
+		case IsIn(name, []string{"--label", "--cap-add", "--cap-drop", "--device", "--dns", "--dns-search", "--expose", "--security-opt", "--tmpfs", "--sysctl", "--group-add", "--add-host", "--label-file"}):
+			key := strings.ReplaceAll(strings.TrimPrefix(name, "--"), "-", "_")
+			switch name {
+			case "--label":
+				key = "labels"
+			case "--add-host":
+				key = "extra_hosts"
+			case "--label-file":
+				key = "label_file"
+			case "--device", "--sysctl":
+				key += "s"
+			}
+			service.Append(key, value)
+		case name == "--health-cmd":
+			healthcheck.Set("test", []string{"CMD-SHELL", value})
+		case IsIn(name, []string{"--health-interval", "--health-timeout", "--health-start-period"}):
+			healthcheck.Set(strings.ReplaceAll(strings.TrimPrefix(name, "--health-"), "-", "_"), value)
+		case name == "--health-retries":
+			retries, err := strconv.Atoi(opt.Value)
+			if err != nil {
+				unmapped = append(unmapped, opt.arg())
+				continue
+			}
+			healthcheck.Set("retries", retries)
+		case name == "--log-driver":
+			logging.Set("driver", value)
+		case name == "--log-opt":
+			options, _ := logging.Get("options").(*yamlMap)
+			if options == nil {
+				options = newYamlMap()
+			}
+			kv := strings.SplitN(value, "=", 2)
+			if len(kv) != 2 {
+				unmapped = append(unmapped, opt.arg())
+				continue
+			}
+			options.Set(kv[0], kv[1])
+			logging.Set("options", options)
+		default:
+			unmapped = append(unmapped, opt.arg())
+		}
+	}
+	if len(healthcheck.keys) > 0 {
+		service.Set("healthcheck", healthcheck)
+	}
+	if len(logging.keys) > 0 {
+		service.Set("logging", logging)
+	}
+	service.Set("command", composeEscape(spec.Command...))
+	return service, unmapped, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+/*
+HistoryCommand implements 'startainer history [-n <count>] [definition]', which prints out the commands executed by startainer,
+with the definition they were executed for, its status, the action, the duration and the exit code.
+With '-replay <number>', the command line of an entry of the history is executed again.
+*/
+func HistoryCommand(containerManagerCmd string, args []string) {
+	fs := newCommandFlagSet("history", "[-n <count>] [definition] | -replay <number> [-force]")
+	flagCount := fs.Int("n", 20, "Number of the most recent entries to print out, 0 for all of them")
+	flagReplay := fs.Int("replay", 0, "Number of the entry of the history whose command line is executed again")
+	flagForce := fs.Bool("force", false, "Do not ask for confirmation before replaying a command line")
+	names, _ := parseCommandArgs(fs, args)
+	entries, err := readHistory()
+	if err != nil {
+		log.Fatalf("Impossible to read the history. %s", err)
+	}
+	if *flagReplay != 0 {
+		if len(names) > 0 {
+			fs.Usage()
+			log.Fatal("-replay does not accept definitions")
+		}
+		exitOnError(replayHistory(entries, *flagReplay, *flagForce))
+		return
+	}
+	if len(names) > 1 {
+		fs.Usage()
+		log.Fatal("Specify at most one definition")
+	}
+	printHistory(entries, names, *flagCount)
+}
+
+// printHistory prints out the most recent entries of the history, optionally only the ones of a definition.
+// Entries are numbered by their position within the whole history, which is the number used by '-replay'.
+func printHistory(entries []historyEntry, definitions []string, count int) {
+	var numbers []int
+	for i, entry := range entries {
+		if len(definitions) == 0 || IsIn(entry.Definition, definitions) {
+			numbers = append(numbers, i+1)
+		}
+	}
+	if count > 0 && len(numbers) > count {
+		numbers = numbers[len(numbers)-count:]
+	}
+	if len(numbers) == 0 {
+		fmt.Println("The history is empty")
+		return
+	}
+	for _, number := range numbers {
+		entry := entries[number-1]
+		exit := fmt.Sprintf("exit %d", entry.ExitCode)
+		if entry.ExitCode != 0 {
+			exit = red(exit)
+		}
+		definition := entry.Definition
+		if definition == "" {
+			definition = "-"
+		}
+		duration := (time.Duration(entry.DurationMs) * time.Millisecond).Round(100 * time.Millisecond)
+		fmt.Printf("%5d  %s  %s %-14s %-12s %s  %s\n", number, entry.Time.Local().Format("2006-01-02 15:04:05"), bold(fmt.Sprintf("%-15s", definition)), entry.Action, entry.Status, exit, duration)
+		fmt.Printf("       %s\n", blue(strings.Join(entry.Command, " ")))
+	}
+}
+
+// replayHistory executes again the command line of the entry of the history having the given number, within the same folder
+func replayHistory(entries []historyEntry, number int, force bool) error {
+	if number < 1 || number > len(entries) {
+		return fmt.Errorf("no entry number %d within the history, which has %d entries", number, len(entries))
+	}
+	entry := entries[number-1]
+	if len(entry.Command) == 0 {
+		return fmt.Errorf("the entry number %d of the history has no command line", number)
+	}
+	log.Printf("Replaying entry %d of %s:\n  %s", number, entry.Time.Local().Format("2006-01-02 15:04:05"), strings.Join(entry.Command, " "))
+	if !force && !Confirm("Execute this command line again?") {
+		log.Print("Command line not executed")
+		return nil
+	}
+	cmd := forDefinition(exec.Command(entry.Command[0], entry.Command[1:]...), entry.Definition)
+	cmd.Dir = entry.Dir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return runCommand(cmd)
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// jsonStringList is a JSON value which can be either a list of strings or a single string,
+// as podman and docker format differently fields such as 'Config.Entrypoint'
+type jsonStringList []string
+
+func (l *jsonStringList) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*l = list
+		return nil
+	}
+	var single string
+	if err := json.Unmarshal(data, &single); err != nil {
+		return err
+	}
+	if single != "" {
+		*l = []string{single}
+	}
+	return nil
+}
+
+// containerConfigJSON is the 'Config' section of the output of 'container inspect' and 'image inspect'
+type containerConfigJSON struct {
+	Image        string         `json:"Image"`
+	Hostname     string         `json:"Hostname"`
+	User         string         `json:"User"`
+	Env          []string       `json:"Env"`
+	Cmd          jsonStringList `json:"Cmd"`
+	Entrypoint   jsonStringList `json:"Entrypoint"`
+	WorkingDir   string         `json:"WorkingDir"`
+	Tty          bool           `json:"Tty"`
+	OpenStdin    bool           `json:"OpenStdin"`
+	AttachStdout bool           `json:"AttachStdout"`
+}
+
+// containerInspectJSON is the part of the output of 'container inspect' needed to re-create a container
+type containerInspectJSON struct {
+	ID         string              `json:"Id"`
+	Name       string              `json:"Name"`
+	Config     containerConfigJSON `json:"Config"`
+	HostConfig struct {
+		AutoRemove    bool   `json:"AutoRemove"`
+		NetworkMode   string `json:"NetworkMode"`
+		Privileged    bool   `json:"Privileged"`
+		RestartPolicy struct {
+			Name              string `json:"Name"`
+			MaximumRetryCount int    `json:"MaximumRetryCount"`
+		} `json:"RestartPolicy"`
+		PortBindings map[string][]struct {
+			HostIP   string `json:"HostIp"`
+			HostPort string `json:"HostPort"`
+		} `json:"PortBindings"`
+	} `json:"HostConfig"`
+	Mounts []struct {
+		Type        string `json:"Type"`
+		Name        string `json:"Name"`
+		Source      string `json:"Source"`
+		Destination string `json:"Destination"`
+		RW          bool   `json:"RW"`
+	} `json:"Mounts"`
+}
+
+// anonymousVolumeRegex matches the names generated for anonymous volumes, which are re-created by the image
+var anonymousVolumeRegex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// inspectJSON executes 'inspect' for a container or image, and decodes the first object of its output into v
+func inspectJSON(containerManagerCmd string, kind string, name string, v interface{}) error {
+	var outb, errb bytes.Buffer
+	cmd := exec.Command(containerManagerCmd, kind, "inspect", name)
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("an error occurred when executing:\n  %s\n%s%s", strings.Join(cmd.Args, " "), errb.String(), err)
+	}
+	var objects []json.RawMessage
+	if err := json.Unmarshal(outb.Bytes(), &objects); err != nil || len(objects) == 0 {
+		return fmt.Errorf("impossible to read output of '%s'. %v", strings.Join(cmd.Args, " "), err)
+	}
+	return json.Unmarshal(objects[0], v)
+}
+
+/*
+runSpecFromContainer reverses the output of 'container inspect' into the arguments of 'docker run':
+image, name, ports, mounts, environment, restart policy, network and command.
+Values which the container inherited from its image, such as environment variables and command, are skipped.
+*/
+func runSpecFromContainer(containerManagerCmd string, containerName string) (runSpec, error) {
+	var container containerInspectJSON
+	var spec runSpec
+	if err := inspectJSON(containerManagerCmd, "container", containerName, &container); err != nil {
+		return spec, err
+	}
+	// the image provides defaults for the environment, command, working dir and user
+	var image struct {
+		Config containerConfigJSON `json:"Config"`
+	}
+	if err := inspectJSON(containerManagerCmd, "image", container.Config.Image, &image); err != nil {
+		log.Printf("Impossible to inspect image '%s', all the configurations of the container will be imported. %s", container.Config.Image, err)
+	}
+	add := func(flag string, value string) {
+		spec.Options = append(spec.Options, runOption{Flag: flag, Value: value, HasValue: value != ""})
+	}
+
+	if !container.Config.AttachStdout {
+		add("-d", "")
+	}
+	if container.HostConfig.AutoRemove {
+		add("--rm", "")
+	}
+	switch {
+	case container.Config.Tty && container.Config.OpenStdin:
+		add("-ti", "")
+	case container.Config.Tty:
+		add("-t", "")
+	case container.Config.OpenStdin:
+		add("-i", "")
+	}
+	if container.HostConfig.Privileged {
+		add("--privileged", "")
+	}
+	if policy := container.HostConfig.RestartPolicy; policy.Name != "" && policy.Name != "no" {
+		if policy.MaximumRetryCount > 0 {
+			add("--restart", fmt.Sprintf("%s:%d", policy.Name, policy.MaximumRetryCount))
+		} else {
+			add("--restart", policy.Name)
+		}
+	}
+	// default networks of docker and podman do not need to be specified
+	if network := container.HostConfig.NetworkMode; !IsIn(network, []string{"", "default", "bridge", "slirp4netns", "pasta", "private"}) {
+		add("--network", network)
+	}
+	if hostname := container.Config.Hostname; hostname != "" && !strings.HasPrefix(container.ID, hostname) && hostname != strings.TrimPrefix(container.Name, "/") {
+		add("--hostname", hostname)
+	}
+
+	var ports []string
+	for port := range container.HostConfig.PortBindings {
+		ports = append(ports, port)
+	}
+	sort.Strings(ports)
+	for _, port := range ports {
+		container_port := strings.TrimSuffix(port, "/tcp")
+		for _, binding := range container.HostConfig.PortBindings[port] {
+			switch {
+			case binding.HostPort == "":
+				add("-p", container_port)
+			case binding.HostIP == "" || binding.HostIP == "0.0.0.0" || binding.HostIP == "::":
+				add("-p", binding.HostPort+":"+container_port)
+			default:
+				add("-p", binding.HostIP+":"+binding.HostPort+":"+container_port)
+			}
+		}
+	}
+
+	for _, mount := range container.Mounts {
+		suffix := ""
+		if !mount.RW {
+			suffix = ":ro"
+		}
+		switch mount.Type {
+		case "bind":
+			add("-v", mount.Source+":"+mount.Destination+suffix)
+		case "volume":
+			if anonymousVolumeRegex.MatchString(mount.Name) {
+				continue
+			}
+			add("-v", mount.Name+":"+mount.Destination+suffix)
+		case "tmpfs":
+			add("--tmpfs", mount.Destination)
+		}
+	}
+
+	for _, env := range container.Config.Env {
+		if !IsIn(env, image.Config.Env) {
+			add("-e", env)
+		}
+	}
+	if container.Config.WorkingDir != image.Config.WorkingDir {
+		add("-w", container.Config.WorkingDir)
+	}
+	if container.Config.User != image.Config.User {
+		add("-u", container.Config.User)
+	}
+
+	spec.Image = container.Config.Image
+	entrypoint_changed := strings.Join(container.Config.Entrypoint, "\x00") != strings.Join(image.Config.Entrypoint, "\x00")
+	if entrypoint_changed && len(container.Config.Entrypoint) > 0 {
+		// '--entrypoint' only accepts the executable, its parameters are provided as command
+		add("--entrypoint", container.Config.Entrypoint[0])
+		spec.Command = append(spec.Command, container.Config.Entrypoint[1:]...)
+	}
+	if entrypoint_changed || strings.Join(container.Config.Cmd, "\x00") != strings.Join(image.Config.Cmd, "\x00") {
+		spec.Command = append(spec.Command, container.Config.Cmd...)
+	}
+	return spec, nil
+}
+
+// runSpecFromCommandLine analyzes a 'docker run ...' command line, as it would be typed within a shell
+func runSpecFromCommandLine(line string) (runSpec, error) {
+	args, err := splitCommandLine(line)
+	if err != nil {
+		return runSpec{}, err
+	}
+	// skip everything before 'run', e.g.: 'sudo docker run', 'podman container run'
+	found := false
+	for i, arg := range args {
+		if arg == "run" {
+			args, found = args[i+1:], true
+			break
+		}
+	}
+	if !found {
+		return runSpec{}, fmt.Errorf("the command line does not contain 'run'")
+	}
+	spec, err := parseRunArgs(args)
+	if err != nil {
+		return spec, err
+	}
+	// shell variables are not expanded by startainer: use its notations for the current folder and the home
+	for i, opt := range spec.Options {
+		if IsIn(canonicalRunFlag(opt.Flag), []string{"--volume", "--mount"}) {
+			for _, variable := range []string{"$(pwd)", "${PWD}", "$PWD"} {
+				opt.Value = strings.Replace(opt.Value, variable, ".", 1)
+			}
+			for _, variable := range []string{"${HOME}", "$HOME"} {
+				opt.Value = strings.Replace(opt.Value, variable, "~", 1)
+			}
+			spec.Options[i] = opt
+		}
+	}
+	return spec, nil
+}
+
+/*
+ImportCommand implements 'startainer import [-name <definition>] <container>' and 'startainer import [-name <definition>] -cmd "docker run ..."'.
+A new container definition is appended to the configuration file, based on an existing container or on a 'run' command line.
+*/
+func ImportCommand(containerManagerCmd string, args []string) {
+	fs := newCommandFlagSet("import", "[-name <definition>] <container> | -cmd \"docker run ...\"")
+	flagName := fs.String("name", "", "Name of the new definition. Defaults to the name of the container")
+	flagCmd := fs.String("cmd", "", "A 'docker run ...' command line to be translated into a definition, instead of an existing container")
+	positional, _ := parseCommandArgs(fs, args)
+
+	var spec runSpec
+	var err error
+	var source string
+	definitionName := *flagName
+	switch {
+	case *flagCmd != "" && len(positional) == 0:
+		if spec, err = runSpecFromCommandLine(*flagCmd); err != nil {
+			log.Fatalf("Impossible to analyze the command line. %s", err)
+		}
+		source = "a 'run' command line"
+		if definitionName == "" {
+			definitionName = spec.Value("--name")
+		}
+		if definitionName == "" {
+			log.Fatal("The command line does not specify '--name': use -name to provide the name of the definition")
+		}
+	case *flagCmd == "" && len(positional) == 1:
+		containerName := positional[0]
+		if spec, err = runSpecFromContainer(containerManagerCmd, containerName); err != nil {
+			log.Fatal(err)
+		}
+		source = "container '" + containerName + "'"
+		if definitionName == "" {
+			definitionName = strings.ToLower(containerName)
+		}
+		if definitionName != containerName {
+			// the definition tracks the imported container by its name
+			spec.Options = append([]runOption{{Flag: "--name", Value: containerName, HasValue: true}}, spec.Options...)
+		}
+		log.Print("Environment variables were copied as they are: review them, and read secrets from the environment instead")
+	default:
+		fs.Usage()
+		log.Fatal("Specify either the name of a container or -cmd")
+	}
+	if err := ValidDefinitionName(definitionName); err != nil {
+		log.Fatal(err)
+	}
+	if spec.Image == "" {
+		log.Fatalf("No image found within %s", source)
+	}
+
+	def := newYamlDefinition(definitionName, fmt.Sprintf("imported from %s on %s", source, time.Now().Format("2006-01-02")))
+	def.Set("image", spec.Image)
+	def.Set("run", spec.Args())
+	log.Printf("Definition imported from %s:\n%s", source, def)
+	if dryRun {
+		explain("would append the definition to the configuration file '%s'", viper.ConfigFileUsed())
+		return
+	}
+	if err := AppendDefinition(def); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Definition '%s' added to configuration file '%s'", definitionName, viper.ConfigFileUsed())
+}
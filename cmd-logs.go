@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// LogOptions are the options supported when showing the logs of a definition
+type LogOptions struct {
+	Follow bool   // keep streaming new log lines
+	Since  string // show logs since timestamp (e.g. 2023-04-23T10:00:00) or relative time (e.g. 42m)
+	Tail   string // number of lines to show from the end of the logs, or "all"
+}
+
+// args returns the command-line parameters corresponding to the options, valid for both 'logs' and 'compose logs'
+func (opts LogOptions) args() []string {
+	var args []string
+	if opts.Follow {
+		args = append(args, "--follow")
+	}
+	if opts.Since != "" {
+		args = append(args, "--since="+opts.Since)
+	}
+	if opts.Tail != "" {
+		args = append(args, "--tail="+opts.Tail)
+	}
+	return args
+}
+
+// colors used for the prefixes of the interleaved log lines, assigned in turn to the definitions
+var prefixColors = []func(a ...interface{}) string{
+	color.New(color.FgCyan).SprintFunc(),
+	color.New(color.FgMagenta).SprintFunc(),
+	color.New(color.FgGreen).SprintFunc(),
+	color.New(color.FgYellow).SprintFunc(),
+	color.New(color.FgBlue).SprintFunc(),
+}
+
+/*
+prefixWriter is an io.Writer which writes each line it receives to 'out', prepended by 'prefix'.
+Writers sharing the same mutex can be used concurrently, without mixing up their lines.
+*/
+type prefixWriter struct {
+	prefix string
+	out    io.Writer
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.writeLine(w.buf[:i+1])
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes out any incomplete line still buffered
+func (w *prefixWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.writeLine(append(w.buf, '\n'))
+		w.buf = nil
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fmt.Fprintf(w.out, "%s %s", w.prefix, line)
+}
+
+// logsCommand prepares the command showing the logs of a container or compose definition.
+// The target can reference specific services of a compose definition, e.g. 'composeexample:web'.
+func logsCommand(containerManagerCmd string, target string, opts LogOptions) (*exec.Cmd, error) {
+	definition, services, err := SplitTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	switch ConfigType(definition) {
+	case CONFTYPECONTAINER:
+		return ContainerLogsCommand(containerManagerCmd, definition, opts), nil
+	case CONFTYPECOMPOSE:
+		return ComposeLogsCommand(containerManagerCmd, definition, ComposeServices(definition, services), opts)
+	default:
+		return nil, fmt.Errorf("'%s' is not a valid definition", definition)
+	}
+}
+
+/*
+LogsCommand implements 'startainer logs <definition|@group ...> [-f] [-since] [-tail]'.
+With a single definition, the logs are shown as-is. With multiple definitions, the logs are
+interleaved, and each line is prefixed by the colored name of its definition.
+*/
+func LogsCommand(containerManagerCmd string, args []string) {
+	var opts LogOptions
+	fs := newCommandFlagSet("logs", "<definition|@group> ... [-f] [-since <time>] [-tail <n>]")
+	fs.BoolVar(&opts.Follow, "f", false, "Follow log output")
+	fs.StringVar(&opts.Since, "since", "", "Show logs since timestamp (e.g. 2023-04-23T10:00:00) or relative time (e.g. 42m)")
+	fs.StringVar(&opts.Tail, "tail", "", "Number of lines to show from the end of the logs, or 'all'")
+	names, _ := parseCommandArgs(fs, args)
+	if len(names) == 0 {
+		fs.Usage()
+		log.Fatal("Specify at least one definition or group")
+	}
+	definitions, err := ResolveDefinitions(names, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// width of the prefixes, so that log lines are aligned
+	width := 0
+	for _, definition := range definitions {
+		if len(definition) > width {
+			width = len(definition)
+		}
+	}
+
+	var cmds []*exec.Cmd
+	var writers []*prefixWriter
+	var mu sync.Mutex
+	for i, definition := range definitions {
+		cmd, err := logsCommand(containerManagerCmd, definition, opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(definitions) == 1 {
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+		} else {
+			prefix := prefixColors[i%len(prefixColors)](fmt.Sprintf("%-*s |", width, definition))
+			stdout := &prefixWriter{prefix: prefix, out: os.Stdout, mu: &mu}
+			stderr := &prefixWriter{prefix: prefix, out: os.Stderr, mu: &mu}
+			cmd.Stdout = stdout
+			cmd.Stderr = stderr
+			writers = append(writers, stdout, stderr)
+		}
+		log.Printf("Showing logs of '%s':\n  %s", definition, strings.Join(cmd.Args, " "))
+		cmds = append(cmds, cmd)
+	}
+
+	failures := 0
+	var wg sync.WaitGroup
+	for i, cmd := range cmds {
+		if err := cmd.Start(); err != nil {
+			log.Printf("Impossible to show logs of '%s'. %s", definitions[i], err)
+			mu.Lock()
+			failures++
+			mu.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func(definition string, cmd *exec.Cmd) {
+			defer wg.Done()
+			if err := cmd.Wait(); err != nil {
+				mu.Lock()
+				failures++
+				mu.Unlock()
+				log.Printf("Logs of '%s' terminated with error. %s", definition, err)
+			}
+		}(definitions[i], cmd)
+	}
+	wg.Wait()
+	for _, w := range writers {
+		w.Flush()
+	}
+	if failures > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+/*
+LsCommand implements 'startainer ls [-watch] [definition|@group ...]', which lists the definitions with their status, as '-l' does.
+With -watch, the runtime events are followed, and the status of a definition is updated when one of its containers changes.
+*/
+func LsCommand(containerManagerCmd string, args []string) {
+	fs := newCommandFlagSet("ls", "[-watch] [definition|@group ...]")
+	flagWatch := fs.Bool("watch", false, "Keep following the events of the runtime, updating the status of the definitions whose containers change")
+	names, _ := parseCommandArgs(fs, args)
+	definitions := DefinitionNames()
+	if len(names) > 0 {
+		var err error
+		if definitions, err = ResolveDefinitions(names, false); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if !*flagWatch {
+		listDefinitions(containerManagerCmd, definitions)
+		return
+	}
+	if err := watchDefinitions(containerManagerCmd, definitions); err != nil {
+		log.Fatal(err)
+	}
+}
+
+/*
+runtimeEvent is an event printed out by 'events --format {{json .}}'.
+docker provides the action and the labels of the container within 'Action' and 'Actor.Attributes',
+podman within 'Status' and 'Attributes'. Both provide the labels together with the name of the container.
+*/
+type runtimeEvent struct {
+	Type         string            `json:"Type"`
+	Status       string            `json:"Status"`
+	Action       string            `json:"Action"`
+	Name         string            `json:"Name"`
+	HealthStatus string            `json:"HealthStatus"`
+	Attributes   map[string]string `json:"Attributes"`
+	Actor        struct {
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+}
+
+// action returns the action of the event, e.g. 'start', 'die' or 'health_status: unhealthy'
+func (e runtimeEvent) action() string {
+	if e.Action != "" {
+		return e.Action
+	}
+	if e.HealthStatus != "" {
+		// podman provides the health separately
+		return e.Status + ": " + e.HealthStatus
+	}
+	return e.Status
+}
+
+// attributes returns the name and labels of the container of the event
+func (e runtimeEvent) attributes() map[string]string {
+	if e.Actor.Attributes != nil {
+		return e.Actor.Attributes
+	}
+	if e.Attributes == nil {
+		return map[string]string{"name": e.Name}
+	}
+	return e.Attributes
+}
+
+// watchedActions are the actions of the containers which can change the status of a definition
+var watchedActions = []string{"create", "start", "restart", "stop", "die", "kill", "pause", "unpause", "destroy", "remove", "health_status"}
+
+// relevant returns true if the event concerns a container, and can change its status
+func (e runtimeEvent) relevant() bool {
+	if e.Type != "" && e.Type != "container" {
+		return false
+	}
+	action := strings.SplitN(e.action(), ":", 2)[0]
+	return IsIn(action, watchedActions)
+}
+
+/*
+eventDefinition returns the definition, among the watched ones, which the container of the event belongs to, or "" if none:
+the container is matched by the label 'startainer.definition', by its name, or by the compose project it belongs to.
+*/
+func eventDefinition(containerManagerCmd string, attributes map[string]string, definitions []string) string {
+	if definition := attributes[DEFINITIONLABEL]; IsIn(definition, definitions) {
+		// the label is also set on the services exported with 'export compose', which are not container definitions
+		if ConfigType(definition) == CONFTYPECONTAINER {
+			return definition
+		}
+	}
+	project := attributes["com.docker.compose.project"]
+	for _, definition := range definitions {
+		switch ConfigType(definition) {
+		case CONFTYPECONTAINER:
+			if project == "" && attributes["name"] == ContainerName(containerManagerCmd, definition) {
+				return definition
+			}
+		case CONFTYPECOMPOSE:
+			if project != "" && composeProjectMatches(definition, project, attributes["com.docker.compose.project.working_dir"]) {
+				return definition
+			}
+		}
+	}
+	return ""
+}
+
+// composeProjectMatches returns true if a compose project is the one of a compose definition: either the one
+// set with 'project_name', or the one within the folder of the compose file, whose name compose derives from the folder
+func composeProjectMatches(composeConfName string, project string, workingDir string) bool {
+	if project_name := viper.GetString(composeConfName + ".project_name"); project_name != "" {
+		return project == project_name
+	}
+	files, err := ComposeFiles(composeConfName)
+	if err != nil {
+		return false
+	}
+	return workingDir != "" && filepath.Clean(workingDir) == filepath.Dir(files[0])
+}
+
+/*
+watchDefinitions prints out the status of the definitions, then follows the events of the runtime: when containers
+belonging to definitions change, only the status of those definitions is computed anew. Within a terminal, the list is
+redrawn in place, otherwise each change is printed out on a new line.
+*/
+func watchDefinitions(containerManagerCmd string, definitions []string) error {
+	statuses := make(map[string]string)
+	for _, definition := range definitions {
+		status, err := DefinitionStatus(containerManagerCmd, definition)
+		if err != nil {
+			return err
+		}
+		statuses[definition] = status
+	}
+
+	cmd := exec.Command(containerManagerCmd, "events", "--filter", "type=container", "--format", "{{json .}}")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("impossible to follow the events of the runtime. Command line arguments were:\n  %s\n%s", strings.Join(cmd.Args, " "), err)
+	}
+	events := make(chan runtimeEvent)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			var event runtimeEvent
+			if err := json.Unmarshal(scanner.Bytes(), &event); err == nil && event.relevant() {
+				events <- event
+			}
+		}
+		close(events)
+	}()
+
+	redraw := term.IsTerminal(int(os.Stdout.Fd()))
+	drawn := 0
+	if redraw {
+		drawn = drawWatchedDefinitions(definitions, statuses, "", 0)
+	} else {
+		for _, definition := range definitions {
+			fmt.Printf("%s %-15s (%s status: %s)\n", time.Now().Format("15:04:05"), definition, ConfigType(definition), styleStatus(statuses[definition]))
+		}
+	}
+
+	for {
+		event, ok := <-events
+		if !ok {
+			cmd.Wait()
+			return fmt.Errorf("the events of the runtime are not available anymore. Command line arguments were:\n  %s", strings.Join(cmd.Args, " "))
+		}
+		/*
+			a single change produces several events, e.g. 'kill', 'die' and 'stop': the events received shortly
+			after the first one are collected, and the status of each affected definition is computed once
+		*/
+		changed := make(map[string]bool)
+		last := ""
+		for collecting := true; collecting; {
+			attributes := event.attributes()
+			if definition := eventDefinition(containerManagerCmd, attributes, definitions); definition != "" {
+				changed[definition] = true
+				last = fmt.Sprintf("%s %s: %s", time.Now().Format("15:04:05"), attributes["name"], event.action())
+			}
+			select {
+			case event, ok = <-events:
+				collecting = ok
+			case <-time.After(300 * time.Millisecond):
+				collecting = false
+			}
+		}
+		for definition := range changed {
+			// the container might have been re-created with another name
+			forgetContainerNames(definition)
+			status, err := DefinitionStatus(containerManagerCmd, definition)
+			if err != nil {
+				status = ERROR
+			}
+			if !redraw && status != statuses[definition] {
+				fmt.Printf("%s %-15s (%s status: %s)\n", time.Now().Format("15:04:05"), definition, ConfigType(definition), styleStatus(status))
+			}
+			statuses[definition] = status
+		}
+		if redraw && len(changed) > 0 {
+			drawn = drawWatchedDefinitions(definitions, statuses, last, drawn)
+		}
+		if !ok {
+			// the channel was closed while collecting the events
+			cmd.Wait()
+			return fmt.Errorf("the events of the runtime are not available anymore. Command line arguments were:\n  %s", strings.Join(cmd.Args, " "))
+		}
+	}
+}
+
+// drawWatchedDefinitions draws the definitions with their status, replacing the lines drawn before. It returns the number of drawn lines.
+func drawWatchedDefinitions(definitions []string, statuses map[string]string, lastEvent string, previous int) int {
+	var b strings.Builder
+	if previous > 0 {
+		// move back to the first line drawn before
+		fmt.Fprintf(&b, "\x1b[%dA", previous)
+	}
+	b.WriteString("\r\x1b[J")
+	b.WriteString(bold("The available container definitions are:") + "\n")
+	for _, definition := range definitions {
+		fmt.Fprintf(&b, "  - %-15s (%s status: %s)\n", definition, ConfigType(definition), styleStatus(statuses[definition]))
+	}
+	if lastEvent == "" {
+		lastEvent = "waiting for events..."
+	}
+	b.WriteString(blue("Last event: "+lastEvent) + " (Ctrl-C to stop)\n")
+	fmt.Print(b.String())
+	return len(definitions) + 2
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"log"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// maximum number of pulls executed in parallel by the 'pull' sub-command
+const MAXCONCURRENTPULLS int = 4
+
+// pullResult collects the outcome of the pull of one image or compose stack
+type pullResult struct {
+	definitions []string
+	target      string
+	oldID       string
+	newID       string
+	skipped     string
+	err         error
+}
+
+// shortID removes the algorithm prefix from an image ID and truncates it for display
+func shortID(id string) string {
+	if len(id) > 7 && id[:7] == "sha256:" {
+		id = id[7:]
+	}
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	return id
+}
+
+/*
+PullCommand implements 'startainer pull <definition|@group ...|--all>'.
+It pulls the images of the selected definitions concurrently, regardless of their pull policy
+(except for 'never'), and reports which images changed.
+For compose definitions, 'compose pull' is executed.
+*/
+func PullCommand(containerManagerCmd string, args []string) {
+	fs := newCommandFlagSet("pull", "[-all] <definition|@group> ...")
+	flagAll := fs.Bool("all", false, "Pull the images of all the definitions")
+	names, _ := parseCommandArgs(fs, args)
+	if len(names) == 0 && !*flagAll {
+		fs.Usage()
+		log.Fatal("Specify at least one definition or group, or -all")
+	}
+	definitions, err := ResolveDefinitions(names, *flagAll)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// collect what needs to be pulled. Images shared by multiple definitions are pulled only once
+	var results []*pullResult
+	images := make(map[string]*pullResult)
+	for _, definition := range definitions {
+		switch ConfigType(definition) {
+		case CONFTYPEUNKNOWN:
+			// a compose definition, targeting specific services
+			results = append(results, &pullResult{definitions: []string{definition}, target: "compose services"})
+		case CONFTYPECOMPOSE:
+			results = append(results, &pullResult{definitions: []string{definition}, target: "compose stack"})
+		case CONFTYPECONTAINER:
+			if HasBuild(definition) {
+				results = append(results, &pullResult{definitions: []string{definition}, skipped: "image is built locally"})
+				continue
+			}
+			if !viper.IsSet(definition + ".image") {
+				results = append(results, &pullResult{definitions: []string{definition}, skipped: "no 'image' configured"})
+				continue
+			}
+			image_name := viper.GetString(definition + ".image")
+			if policy, err := PullPolicy(definition); err != nil {
+				results = append(results, &pullResult{definitions: []string{definition}, target: image_name, err: err})
+				continue
+			} else if policy == PULLNEVER {
+				results = append(results, &pullResult{definitions: []string{definition}, target: image_name, skipped: "pull policy is 'never'"})
+				continue
+			}
+			if result, ok := images[image_name]; ok {
+				result.definitions = append(result.definitions, definition)
+			} else {
+				images[image_name] = &pullResult{definitions: []string{definition}, target: image_name}
+				results = append(results, images[image_name])
+			}
+		}
+	}
+
+	log.Printf("Pulling images for %d definitions", len(definitions))
+	var wg sync.WaitGroup
+	semaphore := make(chan bool, MAXCONCURRENTPULLS)
+	for _, result := range results {
+		if result.skipped != "" || result.err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(result *pullResult) {
+			defer wg.Done()
+			semaphore <- true
+			defer func() { <-semaphore }()
+			if ConfigType(result.definitions[0]) != CONFTYPECONTAINER {
+				definition, services, _ := SplitTarget(result.definitions[0])
+				result.err = ComposePull(containerManagerCmd, definition, ComposeServices(definition, services), false)
+				return
+			}
+			if result.oldID, result.err = ImageID(containerManagerCmd, result.target); result.err != nil {
+				return
+			}
+			if result.err = ImagePull(containerManagerCmd, result.target, false); result.err != nil {
+				return
+			}
+			result.newID, result.err = ImageID(containerManagerCmd, result.target)
+		}(result)
+	}
+	wg.Wait()
+
+	failures := 0
+	log.Print("Pull results:")
+	for _, result := range results {
+		switch {
+		case result.err != nil:
+			failures++
+			log.Printf("  - %-15s %s: %s\n      %v", result.definitions[0], result.target, red("failed"), result.err)
+		case result.skipped != "":
+			log.Printf("  - %-15s skipped: %s", result.definitions[0], result.skipped)
+		case ConfigType(result.definitions[0]) != CONFTYPECONTAINER:
+			log.Printf("  - %-15s %s: %s", result.definitions[0], result.target, green("pulled"))
+		case result.oldID == "":
+			log.Printf("  - %-15s %s: %s (%s)", result.definitions[0], result.target, green("new"), shortID(result.newID))
+		case result.oldID != result.newID:
+			log.Printf("  - %-15s %s: %s (%s -> %s)", result.definitions[0], result.target, green("updated"), shortID(result.oldID), shortID(result.newID))
+		default:
+			log.Printf("  - %-15s %s: up to date (%s)", result.definitions[0], result.target, shortID(result.newID))
+		}
+		for _, definition := range result.definitions[1:] {
+			log.Printf("    also used by %s", definition)
+		}
+	}
+	if failures > 0 {
+		log.Fatalf("%d pulls failed", failures)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"log"
+)
+
+/*
+RestartCommand implements 'startainer restart [-force] <definition|@group ...>'.
+Running containers are stopped, then started again the same way 'startainer <definition>' would:
+this means that containers executed with '--rm' are run anew. Compose stacks, or some of their services, are restarted with 'compose restart'.
+Missing or stopped definitions are simply started.
+*/
+func RestartCommand(containerManagerCmd string, args []string) {
+	fs := newCommandFlagSet("restart", "[-force] <definition|@group> ...")
+	flagForce := fs.Bool("force", false, "Do not ask for confirmation before restarting running definitions")
+	names, _ := parseCommandArgs(fs, args)
+	if len(names) == 0 {
+		fs.Usage()
+		log.Fatal("Specify at least one definition or group")
+	}
+	definitions, err := ResolveDefinitions(names, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, target := range definitions {
+		definition, services, _ := SplitTarget(target)
+		switch ConfigType(definition) {
+		case CONFTYPECONTAINER:
+			err = restartContainer(containerManagerCmd, definition, *flagForce)
+		case CONFTYPECOMPOSE:
+			err = restartCompose(containerManagerCmd, definition, ComposeServices(definition, services), *flagForce)
+		}
+		exitOnError(err)
+	}
+}
+
+// restartContainer restarts the container of a definition. Unless force is true, the user is asked to confirm the restart of a running container.
+func restartContainer(containerManagerCmd string, containerName string, force bool) error {
+	status, err := ContainerStatus(containerManagerCmd, containerName, true)
+	if err != nil {
+		return err
+	}
+	if IsRunning(status) {
+		if !force && !Confirm("The container '"+containerName+"' is running. Restart it?") {
+			log.Printf("Container '%s' not restarted", containerName)
+			return nil
+		}
+		if err := ContainerStop(containerManagerCmd, containerName); err != nil {
+			return err
+		}
+		if dryRun {
+			// nothing was stopped: explain what would happen once the container is, which is removed as well when run with '--rm'
+			status = STOPPED
+			if spec, err := parseRunArgs(RunConfig(containerName)); err == nil && spec.Has("--rm") {
+				status = MISSING
+			}
+			return manageContainerStatus(containerManagerCmd, containerName, status, nil)
+		}
+	} else {
+		log.Printf("The container '%s' is %s, starting it", containerName, styleStatus(status))
+	}
+	// start the container the same way it is done when starting the definition, which also handles containers removed by '--rm'
+	return ManageContainer(containerManagerCmd, containerName, nil)
+}
+
+// restartCompose restarts the services of a compose definition. Unless force is true, the user is asked to confirm the restart of running services.
+func restartCompose(containerManagerCmd string, composeConfName string, services []string, force bool) error {
+	status, err := ComposeStatus(containerManagerCmd, composeConfName, services, true)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case MISSING, COMPOSEFILENOTFOUND:
+		log.Printf("The %s is %s, starting it", describeCompose(composeConfName, services), styleStatus(status))
+		return ManageCompose(containerManagerCmd, composeConfName, services, nil)
+	default:
+		if IsRunning(status) && !force && !Confirm("The "+describeCompose(composeConfName, services)+" is running. Restart it?") {
+			log.Printf("The %s was not restarted", describeCompose(composeConfName, services))
+			return nil
+		}
+		return ComposeRestart(containerManagerCmd, composeConfName, services)
+	}
+}
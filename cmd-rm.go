@@ -0,0 +1,99 @@
+package main
+
+import (
+	"log"
+)
+
+/*
+RemoveCommand implements 'startainer rm [-force] [-volumes] <definition|@group ...>'.
+Containers are stopped if running, then removed. Compose stacks are removed with 'compose down', the services given explicitly (e.g. 'stack:web') with 'compose rm'.
+With -volumes, the anonymous volumes of containers and the volumes of compose stacks are removed as well.
+*/
+func RemoveCommand(containerManagerCmd string, args []string) {
+	fs := newCommandFlagSet("rm", "[-force] [-volumes] <definition|@group> ...")
+	flagForce := fs.Bool("force", false, "Do not ask for confirmation before removing")
+	flagVolumes := fs.Bool("volumes", false, "Remove the anonymous volumes of containers, or the volumes of compose stacks ('compose down -v')")
+	names, _ := parseCommandArgs(fs, args)
+	if len(names) == 0 {
+		fs.Usage()
+		log.Fatal("Specify at least one definition or group")
+	}
+	definitions, err := ResolveDefinitions(names, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, target := range definitions {
+		definition, services, _ := SplitTarget(target)
+		switch ConfigType(definition) {
+		case CONFTYPECONTAINER:
+			removeContainer(containerManagerCmd, definition, *flagForce, *flagVolumes)
+		case CONFTYPECOMPOSE:
+			// the 'services' config only targets the usual services: the whole stack is removed, unless services are given explicitly
+			removeCompose(containerManagerCmd, definition, services, *flagForce, *flagVolumes)
+		}
+	}
+}
+
+func removeContainer(containerManagerCmd string, containerName string, force bool, volumes bool) {
+	status, err := ContainerStatus(containerManagerCmd, containerName, true)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if status == MISSING {
+		log.Printf("The container '%s' does not exist, nothing to remove", containerName)
+		return
+	}
+	question := "Remove the container '" + containerName + "'"
+	if IsRunning(status) {
+		question = "The container '" + containerName + "' is running. Stop and remove it"
+	}
+	if volumes {
+		question += ", including its anonymous volumes"
+	}
+	if !force && !Confirm(question+"?") {
+		log.Printf("Container '%s' not removed", containerName)
+		return
+	}
+	if IsRunning(status) {
+		if err := ContainerStop(containerManagerCmd, containerName); err != nil {
+			log.Fatal(err)
+		}
+		// containers started with '--rm' are removed as soon as they are stopped
+		if status, err = ContainerStatus(containerManagerCmd, containerName, false); err != nil {
+			log.Fatal(err)
+		} else if status == MISSING {
+			log.Printf("Container '%s' removed", containerName)
+			return
+		}
+	}
+	if err := ContainerRemove(containerManagerCmd, containerName, volumes); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Container '%s' removed", containerName)
+}
+
+func removeCompose(containerManagerCmd string, composeConfName string, services []string, force bool, volumes bool) {
+	status, err := ComposeStatus(containerManagerCmd, composeConfName, services, true)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch status {
+	case COMPOSEFILENOTFOUND:
+		log.Fatalf("Configuration file for compose stack '%s' not found", composeConfName)
+	case MISSING:
+		log.Printf("The %s does not exist, nothing to remove", describeCompose(composeConfName, services))
+		return
+	}
+	question := "Stop and remove the containers of " + describeCompose(composeConfName, services)
+	if volumes {
+		question += ", including its volumes"
+	}
+	if !force && !Confirm(question+"?") {
+		log.Printf("The %s was not removed", describeCompose(composeConfName, services))
+		return
+	}
+	if err := ComposeDown(containerManagerCmd, composeConfName, services, volumes); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("The %s was removed", describeCompose(composeConfName, services))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// RUNONCELABEL is the label set on the ephemeral containers of 'run-once', whose value is the name of their definition.
+// It differs from DEFINITIONLABEL, so that they are never mistaken for the container of the definition.
+const RUNONCELABEL string = "startainer.run-once"
+
+// runOnceSkippedFlags are the flags of the 'run' configuration which only make sense for the long-lived container of a definition
+var runOnceSkippedFlags = []string{"--name", "--detach", "--rm", "--restart", "--publish", "--publish-all", "--cidfile",
+	"--health-cmd", "--health-interval", "--health-retries", "--health-start-period", "--health-timeout", "--no-healthcheck",
+	"--interactive", "--tty"}
+
+/*
+runOnceArgs returns the parameters of 'run' for an ephemeral container of a definition: its image, volumes, mounts,
+environment and the other settings of its 'run' configuration are kept, while its name, published ports, restart
+policy and health check are dropped. If no command is provided, the one of the definition is executed.
+*/
+func runOnceArgs(definition string, name string, command []string, interactive bool) ([]string, error) {
+	spec, err := parseRunArgs(RunConfig(definition))
+	if err != nil {
+		return nil, err
+	}
+	run_args := []string{"run", "--rm", "--name=" + name, "--label=" + RUNONCELABEL + "=" + definition}
+	if interactive {
+		run_args = append(run_args, "-i", "-t")
+	} else {
+		run_args = append(run_args, "-i")
+	}
+	var kept []string
+	for _, opt := range spec.Options {
+		if !opt.HasValue {
+			// combined flags such as '-dit' are split up
+			for _, flag := range opt.names() {
+				if !IsIn(flag, runOnceSkippedFlags) {
+					kept = append(kept, flag)
+				}
+			}
+		} else if !IsIn(canonicalRunFlag(opt.Flag), runOnceSkippedFlags) {
+			kept = append(kept, opt.arg())
+		}
+	}
+	kept, _ = expandRunPaths(kept)
+	run_args = append(append(run_args, kept...), spec.Image)
+	if len(command) == 0 {
+		command = spec.Command
+	}
+	return append(run_args, command...), nil
+}
+
+/*
+RunOnceCommand implements 'startainer run-once <definition> [command ...]', which executes a command within an
+ephemeral container created from the image, volumes and environment of a container definition, streaming its output.
+The container is removed once the command terminates, and startainer exits with the exit code of the command.
+The long-lived container of the definition is not affected.
+*/
+func RunOnceCommand(containerManagerCmd string, args []string) {
+	fs := newCommandFlagSet("run-once", "<definition> [command ...]")
+	// flags are parsed only before the definition: the ones following it belong to the command
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		log.Fatal("Specify the container definition to run the command with")
+	}
+	definition, command := fs.Arg(0), fs.Args()[1:]
+	if ConfigType(definition) != CONFTYPECONTAINER {
+		log.Fatalf("'%s' is not a container definition, only container definitions can be used with 'run-once'", definition)
+	}
+	if err := prepareImage(containerManagerCmd, definition); err != nil {
+		log.Fatal(err)
+	}
+
+	interactive, err := terminalAllowed(definition)
+	if err != nil {
+		log.Fatal(err)
+	}
+	name := fmt.Sprintf("%s-once-%d", definition, os.Getpid())
+	run_args, err := runOnceArgs(definition, name, command, interactive)
+	if err != nil {
+		log.Fatalf("Impossible to analyze the 'run' configuration of '%s'. %s", definition, err)
+	}
+	explain("an ephemeral container '%s' will be run, and removed once the command terminates", name)
+
+	cmd := forDefinition(exec.Command(containerManagerCmd, run_args...), definition)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	log.Printf("Running an ephemeral container of '%s'. Command line arguments are:\n  %s", definition, strings.Join(cmd.Args, " "))
+	err = runCommand(cmd)
+	switch err.(type) {
+	case nil:
+	case *exec.ExitError:
+		// the exit code of the command, or the one of the runtime if the container could not be run (e.g. 125)
+		exitError, _ := err.(*exec.ExitError)
+		log.Printf("The command terminated with exit code %d", exitError.ExitCode())
+		os.Exit(exitError.ExitCode())
+	default:
+		log.Fatalf("An error occurred when running the ephemeral container of '%s'. %s", definition, err)
+	}
+}
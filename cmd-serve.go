@@ -0,0 +1,409 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/spf13/viper"
+)
+
+// OpenAPI describes the HTTP API of 'startainer serve'. It is served at /openapi.yaml
+//
+//go:embed openapi.yaml
+var OpenAPI string
+
+// apiDefinition is the JSON representation of a definition and its status
+type apiDefinition struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Status   string   `json:"status"`
+	Services []string `json:"services,omitempty"`
+}
+
+/*
+apiServer implements the HTTP API of 'startainer serve'. The actions use the Controller of the definitions,
+thus the same code executed by 'startainer <definition>', '-down' and 'restart'.
+*/
+type apiServer struct {
+	containerManagerCmd string
+	token               string
+	// listenAddr is the TCP address the API listens on, nil for a unix socket. Requests must target it within their Host header
+	listenAddr *net.TCPAddr
+	// mu serializes the status checks and the actions, which share the cache of the names of the containers
+	mu sync.Mutex
+}
+
+/*
+ServeCommand implements 'startainer serve [-listen <address>] [-token <token>]', which exposes the definitions through
+an HTTP/JSON API, listening on a TCP address or, with 'unix:<path>', on a unix socket.
+Requests over TCP must provide a token as 'Authorization: Bearer <token>': the one provided (or set within the
+STARTAINER_TOKEN environment variable), otherwise a random one printed out at startup. Otherwise, any web page
+opened within a browser could control the definitions through 127.0.0.1.
+*/
+func ServeCommand(containerManagerCmd string, args []string) {
+	fs := newCommandFlagSet("serve", "[-listen <host:port|unix:path>] [-token <token>]")
+	flagListen := fs.String("listen", "127.0.0.1:8642", "TCP address, or 'unix:<path>' of a unix socket, the API listens on")
+	flagToken := fs.String("token", os.Getenv("STARTAINER_TOKEN"), "Token required within the 'Authorization: Bearer <token>' header of the requests. Defaults to the STARTAINER_TOKEN environment variable")
+	parseCommandArgs(fs, args)
+
+	listener, err := apiListen(*flagListen)
+	if err != nil {
+		log.Fatalf("Impossible to listen on '%s'. %s", *flagListen, err)
+	}
+	server := &apiServer{containerManagerCmd: containerManagerCmd, token: *flagToken}
+	if tcp, ok := listener.Addr().(*net.TCPAddr); ok {
+		server.listenAddr = tcp
+		if server.token == "" {
+			if server.token, err = randomToken(); err != nil {
+				log.Fatalf("Impossible to generate a token. %s", err)
+			}
+			log.Printf("No token provided: requests must provide the header 'Authorization: Bearer %s'", server.token)
+		}
+	}
+	http_server := &http.Server{Handler: server.routes()}
+	// on termination, close the server, so that the unix socket is removed
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		log.Print("Terminating the API server")
+		http_server.Close()
+	}()
+
+	log.Printf("Serving the API on '%s'. Its description is available at /openapi.yaml", *flagListen)
+	if err := http_server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
+
+// apiListen listens on a TCP address, or on a unix socket if the address is 'unix:<path>'
+func apiListen(address string) (net.Listener, error) {
+	if !strings.HasPrefix(address, "unix:") {
+		return net.Listen("tcp", address)
+	}
+	path, err := ExpandPath(strings.TrimPrefix(address, "unix:"))
+	if err != nil {
+		return nil, err
+	}
+	// a socket left behind by a server which was not terminated properly
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// only the user can send requests through the socket
+	return listener, os.Chmod(path, 0600)
+}
+
+// randomToken returns a random token, used when none is provided to 'startainer serve'
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// routes returns the handler of the requests, checking their origin and their token before dispatching them
+func (s *apiServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		fmt.Fprint(w, OpenAPI)
+	})
+	mux.HandleFunc("/v1/definitions", s.authorized(s.handleList))
+	mux.HandleFunc("/v1/definitions/", s.authorized(s.handleDefinition))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := s.checkOrigin(r); err != nil {
+			log.Printf("%s %s refused: %s", r.Method, r.URL.RequestURI(), err)
+			writeAPIError(w, http.StatusForbidden, err)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+/*
+checkOrigin refuses the requests which might have been sent by a web page opened within a browser:
+  - the ones having a Host header differing from the address the API listens on, as sent after a DNS rebinding
+    (if the API listens on all the interfaces, any IP address and 'localhost' are accepted);
+  - the ones having an Origin header differing from the API, as sent by cross-site requests.
+*/
+func (s *apiServer) checkOrigin(r *http.Request) error {
+	if s.listenAddr != nil {
+		host, port, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			return fmt.Errorf("invalid host '%s'", r.Host)
+		}
+		host = strings.ToLower(host)
+		ip := net.ParseIP(host)
+		valid := false
+		if port == fmt.Sprint(s.listenAddr.Port) {
+			switch {
+			case s.listenAddr.IP.IsUnspecified():
+				valid = ip != nil || host == "localhost"
+			case s.listenAddr.IP.IsLoopback() && host == "localhost":
+				valid = true
+			default:
+				valid = ip != nil && ip.Equal(s.listenAddr.IP)
+			}
+		}
+		if !valid {
+			return fmt.Errorf("host '%s' not allowed", r.Host)
+		}
+	}
+	if origin := r.Header.Get("Origin"); origin != "" && origin != "http://"+r.Host {
+		return fmt.Errorf("origin '%s' not allowed", origin)
+	}
+	return nil
+}
+
+// authorized wraps a handler, which is executed only if the request provides the token
+func (s *apiServer) authorized(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("%s %s", r.Method, r.URL.RequestURI())
+		if s.token != "" {
+			provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(s.token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeAPIError(w, http.StatusUnauthorized, fmt.Errorf("missing or invalid token"))
+				return
+			}
+		}
+		handler(w, r)
+	}
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+// writeAPIError writes an error response, e.g. {"error": "..."}
+func writeAPIError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// definitionStatus returns the definition with its status. The target can reference services of a compose definition.
+func (s *apiServer) definitionStatus(target string) (apiDefinition, error) {
+	definition, services, err := SplitTarget(target)
+	if err != nil {
+		return apiDefinition{}, err
+	}
+	ctrl, err := NewController(s.containerManagerCmd, target)
+	if err != nil {
+		return apiDefinition{}, err
+	}
+	status, err := ctrl.Status()
+	if err != nil {
+		return apiDefinition{}, err
+	}
+	result := apiDefinition{Name: definition, Type: ConfigType(definition), Status: status}
+	if result.Type == CONFTYPECOMPOSE {
+		result.Services = ComposeServices(definition, services)
+	}
+	return result, nil
+}
+
+// handleList handles GET /v1/definitions
+func (s *apiServer) handleList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// containers might have been re-created since the previous request
+	forgetContainerNames()
+	definitions := []apiDefinition{}
+	for _, name := range DefinitionNames() {
+		definition, err := s.definitionStatus(name)
+		if err != nil {
+			definition = apiDefinition{Name: name, Type: ConfigType(name), Status: ERROR}
+		}
+		definitions = append(definitions, definition)
+	}
+	writeJSON(w, http.StatusOK, definitions)
+}
+
+// handleDefinition handles the requests for a single definition: /v1/definitions/<definition>[/<action>]
+func (s *apiServer) handleDefinition(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/v1/definitions/"), "/", 2)
+	target, action := parts[0], ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+	if definition, _, err := SplitTarget(target); err != nil || ConfigType(definition) == CONFTYPEUNKNOWN {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("definition '%s' not found", target))
+		return
+	}
+	method := http.MethodPost
+	if action == "" || action == "logs" {
+		method = http.MethodGet
+	}
+	if r.Method != method {
+		writeAPIError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	switch action {
+	case "":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		forgetContainerNames()
+		definition, err := s.definitionStatus(target)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, definition)
+	case "up", "down", "restart":
+		s.handleAction(w, target, action)
+	case "logs":
+		s.handleLogs(w, r, target)
+	default:
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("unknown action '%s'", action))
+	}
+}
+
+/*
+startsDetached returns true if starting a definition does not attach to the terminal: the API cannot provide one.
+Container definitions must be run with '-d' and must not be started with '-a' or '-i', compose definitions must be 'up'-ed with '-d' or '--wait'.
+*/
+func startsDetached(definition string) bool {
+	switch ConfigType(definition) {
+	case CONFTYPECONTAINER:
+		spec, err := parseRunArgs(RunConfig(definition))
+		if err != nil || !spec.Has("--detach") {
+			return false
+		}
+		for _, arg := range viper.GetStringSlice(definition + ".start") {
+			if arg == "--attach" || arg == "--interactive" || (strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.ContainsAny(arg, "ai")) {
+				return false
+			}
+		}
+		return true
+	case CONFTYPECOMPOSE:
+		up_args := viper.GetStringSlice(definition + ".up")
+		return IsIn("-d", up_args) || IsIn("--detach", up_args) || IsIn("--wait", up_args)
+	}
+	return false
+}
+
+// handleAction handles POST /v1/definitions/<definition>/{up,down,restart}, returning the status of the definition after the action
+func (s *apiServer) handleAction(w http.ResponseWriter, target string, action string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	forgetContainerNames()
+	ctrl, err := NewController(s.containerManagerCmd, target)
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, err)
+		return
+	}
+	status, err := ctrl.Status()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	definition, _, _ := SplitTarget(target)
+	foreground := fmt.Errorf("'%s' is started in foreground, and cannot be started through the API. Add '-d' to its 'run' (or 'up') configuration", definition)
+	switch action {
+	case "up":
+		if status == RUNNING || status == UNHEALTHY {
+			// starting a running definition would attach a session to it
+			break
+		}
+		if !startsDetached(definition) {
+			writeAPIError(w, http.StatusConflict, foreground)
+			return
+		}
+		err = ctrl.Start()
+	case "down":
+		err = ctrl.Stop()
+	case "restart":
+		// containers are restarted by running or starting them anew, as are missing compose stacks
+		if (ConfigType(definition) == CONFTYPECONTAINER || status == MISSING) && !startsDetached(definition) {
+			writeAPIError(w, http.StatusConflict, foreground)
+			return
+		}
+		err = ctrl.Restart()
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	forgetContainerNames()
+	result, err := s.definitionStatus(target)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// flushWriter sends the data to the client as soon as it is written
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (fw flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if flusher, ok := fw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
+
+// handleLogs handles GET /v1/definitions/<definition>/logs?follow=true&since=<time>&tail=<n>, streaming the logs as plain text
+func (s *apiServer) handleLogs(w http.ResponseWriter, r *http.Request, target string) {
+	query := r.URL.Query()
+	opts := LogOptions{
+		Follow: query.Get("follow") == "true" || query.Get("follow") == "1",
+		Since:  query.Get("since"),
+		Tail:   query.Get("tail"),
+	}
+	s.mu.Lock()
+	cmd, err := logsCommand(s.containerManagerCmd, target, opts)
+	s.mu.Unlock()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	// stdout and stderr share the same writer, which is thus not used concurrently
+	writer := flushWriter{w}
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	if err := cmd.Start(); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	// stop following the logs when the client disconnects
+	done := make(chan bool)
+	go func() {
+		select {
+		case <-r.Context().Done():
+			cmd.Process.Kill()
+		case <-done:
+		}
+	}()
+	cmd.Wait()
+	close(done)
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// uiRow is a definition shown by the dashboard
+type uiRow struct {
+	name       string
+	kind       string
+	status     string
+	containers []string // names of the containers of the definition, used to match the output of 'stats'
+	cpu        string
+	memory     string
+}
+
+// containerStats is the resource usage of a running container, as reported by 'stats'
+type containerStats struct {
+	cpu    float64 // percentage
+	memory float64 // bytes
+}
+
+/*
+dashboard implements 'startainer ui'. The status of the definitions is computed with DefinitionStatus, as done by '-l',
+and the actions use the Controller of the definitions, which executes the same code as 'startainer <definition>'.
+While the dashboard is shown, the log messages are captured, and the last one is displayed at the bottom of the screen.
+*/
+type dashboard struct {
+	containerManagerCmd string
+	rows                []uiRow
+	selected            int
+	message             string
+	refreshed           time.Time
+	logOutput           io.Writer // the output of the logger before the dashboard was shown
+	terminal            *term.State
+}
+
+// Write receives the log messages while the dashboard is shown, and keeps the last line to display it
+func (d *dashboard) Write(p []byte) (int, error) {
+	lines := strings.Split(strings.TrimSpace(string(p)), "\n")
+	if line := strings.TrimSpace(strings.TrimPrefix(lines[len(lines)-1], log.Prefix())); line != "" {
+		d.message = line
+	}
+	return len(p), nil
+}
+
+/*
+UICommand implements 'startainer ui [-interval <duration>] [definition|@group ...]', a terminal dashboard
+showing the definitions with their live status and resource usage. Keys:
+  - up/down (or k/j): select a definition
+  - Enter or s: start the definition, as 'startainer <definition>' does
+  - x: stop the definition, as '-down' does
+  - r: restart the definition, without asking for confirmation
+  - e: attach a session to the running definition
+  - l: tail the logs of the definition, until Ctrl-C is pressed
+  - i: show the configurations of the definition, and the output of 'container inspect'
+  - q, Esc or Ctrl-C: quit
+*/
+func UICommand(containerManagerCmd string, args []string) {
+	fs := newCommandFlagSet("ui", "[-interval <duration>] [definition|@group ...]")
+	flagInterval := fs.Duration("interval", 2*time.Second, "Time between the refreshes of the status and resource usage")
+	names, _ := parseCommandArgs(fs, args)
+	definitions := DefinitionNames()
+	if len(names) > 0 {
+		var err error
+		if definitions, err = ResolveDefinitions(names, false); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if len(definitions) == 0 {
+		log.Fatal("No definitions available within the configuration file")
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		log.Fatal("The dashboard needs a terminal. Use '-l' to list the definitions and their status")
+	}
+
+	d := &dashboard{containerManagerCmd: containerManagerCmd, logOutput: log.Writer()}
+	for _, definition := range definitions {
+		d.rows = append(d.rows, uiRow{name: definition, kind: ConfigType(definition)})
+	}
+	d.run(*flagInterval)
+}
+
+// run shows the dashboard until the user quits it
+func (d *dashboard) run(interval time.Duration) {
+	if err := d.enterScreen(); err != nil {
+		log.Fatal(err)
+	}
+	defer d.leaveScreen()
+
+	/*
+		keys are read by a goroutine, which waits for the key to be handled before reading the following one:
+		this way no input is stolen from the sessions and logs shown while the dashboard is suspended
+	*/
+	keys := make(chan string)
+	handled := make(chan bool)
+	go func() {
+		buf := make([]byte, 16)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			for _, key := range splitKeys(buf[:n]) {
+				keys <- key
+				if !<-handled {
+					return
+				}
+			}
+		}
+	}()
+	stats := make(chan map[string]containerStats, 1)
+	collecting := false
+	// 'stats' takes a while to sample the usage: it is collected in the background, after each refresh
+	collect := func() {
+		if !collecting {
+			collecting = true
+			go collectStats(d.containerManagerCmd, d.runningContainers(), stats)
+		}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	d.message = "Retrieving the status of the definitions..."
+	d.draw()
+	d.refresh()
+	collect()
+	for {
+		d.draw()
+		select {
+		case <-ticker.C:
+			d.refresh()
+			collect()
+		case usage := <-stats:
+			collecting = false
+			d.applyStats(usage)
+		case key, ok := <-keys:
+			if !ok {
+				return
+			}
+			quit := d.handleKey(key)
+			handled <- !quit
+			if quit {
+				return
+			}
+		}
+	}
+}
+
+// splitKeys splits the input read from the terminal into keys: escape sequences, such as the arrows, are kept together
+func splitKeys(input []byte) []string {
+	var keys []string
+	for len(input) > 0 {
+		size := 1
+		if input[0] == 27 && len(input) >= 3 && (input[1] == '[' || input[1] == 'O') {
+			size = 3
+		}
+		keys = append(keys, string(input[:size]))
+		input = input[size:]
+	}
+	return keys
+}
+
+// enterScreen switches the terminal to the alternate screen and to raw mode, and captures the log messages
+func (d *dashboard) enterScreen() error {
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return fmt.Errorf("impossible to set up the terminal. %s", err)
+	}
+	d.terminal = state
+	log.SetOutput(d)
+	dryRunLog.SetOutput(d)
+	// alternate screen, hidden cursor
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	return nil
+}
+
+// leaveScreen restores the terminal and the output of the log messages
+func (d *dashboard) leaveScreen() {
+	fmt.Print("\x1b[?25h\x1b[?1049l")
+	if d.terminal != nil {
+		term.Restore(int(os.Stdin.Fd()), d.terminal)
+		d.terminal = nil
+	}
+	log.SetOutput(d.logOutput)
+	dryRunLog.SetOutput(os.Stderr)
+}
+
+// refresh updates the status of the definitions and the names of their containers
+func (d *dashboard) refresh() {
+	// containers might have been created or removed meanwhile, also outside of startainer
+	forgetContainerNames()
+	for i, row := range d.rows {
+		status, err := DefinitionStatus(d.containerManagerCmd, row.name)
+		if err != nil {
+			status = ERROR
+			d.message = fmt.Sprintf("Impossible to retrieve the status of '%s'. %s", row.name, strings.Split(err.Error(), "\n")[0])
+		}
+		d.rows[i].status = status
+		d.rows[i].containers = nil
+		switch {
+		case !IsRunning(status):
+			d.rows[i].cpu, d.rows[i].memory = "", ""
+		case row.kind == CONFTYPECONTAINER:
+			d.rows[i].containers = []string{ContainerName(d.containerManagerCmd, row.name)}
+		case row.kind == CONFTYPECOMPOSE:
+			instances, _, _, _ := composePS(d.containerManagerCmd, row.name, ComposeServices(row.name, nil))
+			for _, instance := range instances {
+				d.rows[i].containers = append(d.rows[i].containers, instance.Name)
+			}
+		}
+	}
+	if d.message == "Retrieving the status of the definitions..." {
+		d.message = ""
+	}
+	d.refreshed = time.Now()
+}
+
+// runningContainers returns the names of the containers of the running definitions
+func (d *dashboard) runningContainers() []string {
+	var names []string
+	for _, row := range d.rows {
+		names = append(names, row.containers...)
+	}
+	return names
+}
+
+// applyStats sets the resource usage of the definitions, summing up the ones of the containers of compose stacks
+func (d *dashboard) applyStats(usage map[string]containerStats) {
+	for i, row := range d.rows {
+		if len(row.containers) == 0 {
+			continue
+		}
+		var total containerStats
+		found := false
+		for _, name := range row.containers {
+			if stats, ok := usage[name]; ok {
+				total.cpu += stats.cpu
+				total.memory += stats.memory
+				found = true
+			}
+		}
+		if found {
+			d.rows[i].cpu = fmt.Sprintf("%.2f%%", total.cpu)
+			d.rows[i].memory = formatSize(total.memory)
+		}
+	}
+}
+
+// collectStats sends the resource usage of the containers, by container name, retrieved with a single 'stats' command
+func collectStats(containerManagerCmd string, containers []string, result chan<- map[string]containerStats) {
+	usage := make(map[string]containerStats)
+	if len(containers) == 0 {
+		result <- usage
+		return
+	}
+	var outb bytes.Buffer
+	cmd := exec.Command(containerManagerCmd, append([]string{"stats", "--no-stream", "--format", "{{.Name}}\t{{.CPUPerc}}\t{{.MemUsage}}"}, containers...)...)
+	cmd.Stdout = &outb
+	// containers stopped in the meantime make 'stats' fail: the usage of the other ones is kept anyway
+	cmd.Run()
+	for _, line := range strings.Split(outb.String(), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		cpu, _ := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(fields[1]), "%"), 64)
+		// e.g. '12.5MiB / 7.6GiB': the limit is not shown
+		memory, _ := parseSize(strings.SplitN(fields[2], "/", 2)[0])
+		usage[strings.TrimSpace(fields[0])] = containerStats{cpu: cpu, memory: memory}
+	}
+	result <- usage
+}
+
+var sizeRegex = regexp.MustCompile(`^([0-9.]+)\s*([a-zA-Z]*)$`)
+
+// sizeUnits are the units used by docker and podman when reporting sizes
+var sizeUnits = map[string]float64{
+	"": 1, "b": 1,
+	"kb": 1e3, "mb": 1e6, "gb": 1e9, "tb": 1e12,
+	"kib": 1 << 10, "mib": 1 << 20, "gib": 1 << 30, "tib": 1 << 40,
+}
+
+// parseSize converts a size such as '12.5MiB' or '1.2GB' into bytes
+func parseSize(size string) (float64, error) {
+	match := sizeRegex.FindStringSubmatch(strings.TrimSpace(size))
+	if match == nil {
+		return 0, fmt.Errorf("invalid size '%s'", size)
+	}
+	unit, ok := sizeUnits[strings.ToLower(match[2])]
+	if !ok {
+		return 0, fmt.Errorf("invalid unit of size '%s'", size)
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	return value * unit, err
+}
+
+// formatSize formats a size in bytes with a binary unit, e.g. '12.5MiB'
+func formatSize(size float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB"}
+	unit := 0
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f%s", size, units[unit])
+}
+
+// pad appends spaces to a colored text, whose visible length is the one of plain
+func pad(text string, plain string, width int) string {
+	if len(plain) >= width {
+		return text
+	}
+	return text + strings.Repeat(" ", width-len(plain))
+}
+
+// draw draws the whole dashboard
+func (d *dashboard) draw() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || height < 8 {
+		width, height = 80, 25
+	}
+	name_width := 20
+	for _, row := range d.rows {
+		if len(row.name) >= name_width {
+			name_width = len(row.name) + 1
+		}
+	}
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	refreshed := "never"
+	if !d.refreshed.IsZero() {
+		refreshed = d.refreshed.Format("15:04:05")
+	}
+	title := fmt.Sprintf("startainer %s - runtime: %s - refreshed at %s", VERSION, d.containerManagerCmd, refreshed)
+	if dryRun {
+		title += " - dry-run"
+	}
+	b.WriteString(bold(title) + "\r\n\r\n")
+	b.WriteString(bold(fmt.Sprintf("  %-*s %-10s %-20s %8s %10s", name_width, "DEFINITION", "TYPE", "STATUS", "CPU", "MEMORY")) + "\r\n")
+
+	// scroll the list so that the selected definition is visible
+	rows := height - 6
+	first := 0
+	if d.selected >= rows {
+		first = d.selected - rows + 1
+	}
+	for i := first; i < len(d.rows) && i < first+rows; i++ {
+		row := d.rows[i]
+		marker, name := "  ", fmt.Sprintf("%-*s", name_width, row.name)
+		if i == d.selected {
+			marker, name = bold("> "), bold(name)
+		}
+		fmt.Fprintf(&b, "%s%s %-10s %s %8s %10s\r\n", marker, name, row.kind, pad(styleStatus(row.status), row.status, 20), row.cpu, row.memory)
+	}
+
+	// the footer is drawn at the bottom of the screen
+	fmt.Fprintf(&b, "\x1b[%d;1H", height-1)
+	message := d.message
+	if len(message) > width {
+		message = message[:width]
+	}
+	b.WriteString(message + "\r\n")
+	b.WriteString(blue("enter/s start  x stop  r restart  e exec  l logs  i inspect  q quit"))
+	fmt.Print(b.String())
+}
+
+// handleKey performs the action bound to a key. It returns true if the user wants to quit.
+func (d *dashboard) handleKey(key string) bool {
+	switch key {
+	case "q", "\x03", "\x1b":
+		return true
+	case "\x1b[A", "\x1bOA", "k", "\x10":
+		if d.selected > 0 {
+			d.selected--
+		}
+		return false
+	case "\x1b[B", "\x1bOB", "j", "\x0e":
+		if d.selected < len(d.rows)-1 {
+			d.selected++
+		}
+		return false
+	}
+
+	row := d.rows[d.selected]
+	ctrl, err := NewController(d.containerManagerCmd, row.name)
+	if err != nil {
+		d.message = err.Error()
+		return false
+	}
+	switch key {
+	case "\r", "\n", "s":
+		d.suspend(func() error { return ctrl.Start() })
+	case "x":
+		if !IsRunning(row.status) {
+			d.message = fmt.Sprintf("'%s' is %s, nothing to stop", row.name, row.status)
+			return false
+		}
+		d.message = fmt.Sprintf("Stopping '%s'...", row.name)
+		d.draw()
+		if err := ctrl.Stop(); err != nil {
+			d.message = fmt.Sprintf("Impossible to stop '%s'. %s", row.name, strings.Split(err.Error(), "\n")[0])
+		} else if !dryRun {
+			d.message = fmt.Sprintf("'%s' stopped", row.name)
+		}
+	case "r":
+		d.suspend(ctrl.Restart)
+	case "e":
+		if !IsRunning(row.status) {
+			d.message = fmt.Sprintf("'%s' is not running: use enter or 's' to start it", row.name)
+			return false
+		}
+		d.suspend(func() error { return ctrl.Start() })
+	case "l":
+		d.suspend(func() error {
+			cmd, err := logsCommand(d.containerManagerCmd, row.name, LogOptions{Follow: true, Tail: "100"})
+			if err != nil {
+				return err
+			}
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			fmt.Fprintln(os.Stderr, blue(fmt.Sprintf("Logs of '%s'. Press Ctrl-C to stop following them", row.name)))
+			cmd.Run()
+			return nil
+		})
+	case "i":
+		d.suspend(func() error {
+			configs, err := ctrl.List()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s (%s status: %s)\n\n%s\n", bold(row.name), row.kind, styleStatus(row.status), configs)
+			for _, container := range row.containers {
+				cmd := exec.Command(d.containerManagerCmd, "container", "inspect", container)
+				cmd.Stdout = os.Stdout
+				cmd.Stderr = os.Stderr
+				cmd.Run()
+			}
+			return nil
+		})
+	default:
+		return false
+	}
+	d.refresh()
+	return false
+}
+
+/*
+suspend leaves the dashboard to execute an action which uses the terminal, such as a session attached to a container.
+Ctrl-C interrupts the action, not the dashboard, which is shown again once the user presses Enter.
+*/
+func (d *dashboard) suspend(action func() error) {
+	d.leaveScreen()
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	if err := action(); err != nil {
+		fmt.Fprintln(os.Stderr, red(err))
+	}
+	fmt.Fprint(os.Stderr, blue("\nPress Enter to go back to the dashboard"))
+	buf := make([]byte, 64)
+	os.Stdin.Read(buf)
+	signal.Stop(interrupts)
+	if err := d.enterScreen(); err != nil {
+		log.Fatal(err)
+	}
+	d.message = ""
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// commandFunc is the signature of the functions implementing the sub-commands of startainer.
+// They receive the container manager command and the command-line arguments following the name of the sub-command.
+type commandFunc func(containerManagerCmd string, args []string)
+
+// command describes a sub-command of startainer
+type command struct {
+	run     commandFunc
+	summary string // one-line description printed within the usage message
+}
+
+// subcommands maps the names of the sub-commands to their implementation.
+// Sub-commands have precedence over definitions having the same name.
+// The map is filled in by init(), as some commands reference it themselves.
+var subcommands map[string]command
+
+func init() {
+	subcommands = map[string]command{
+		"config":   {ConfigCommand, "Add, edit, remove or rename the definitions within the configuration file"},
+		"export":   {ExportCommand, "Translate container definitions into a compose file, systemd units or Quadlet files"},
+		"history":  {HistoryCommand, "Show the commands executed by startainer, or execute one of them again with -replay"},
+		"import":   {ImportCommand, "Add a definition based on an existing container, or on a 'docker run' command line"},
+		"logs":     {LogsCommand, "Show the logs of container and compose definitions, interleaving them"},
+		"ls":       {LsCommand, "List the definitions with their status, optionally following the changes with -watch"},
+		"pull":     {PullCommand, "Pull the images of definitions, reporting which ones changed"},
+		"restart":  {RestartCommand, "Restart container and compose definitions"},
+		"rm":       {RemoveCommand, "Stop and remove the containers of definitions, optionally with their volumes"},
+		"run-once": {RunOnceCommand, "Run a command within an ephemeral container of a definition, returning its exit code"},
+		"serve":    {ServeCommand, "Expose the definitions through a local HTTP/JSON API"},
+		"ui":       {UICommand, "Show a dashboard of the definitions, with their live status and resource usage"},
+	}
+}
+
+// printCommands writes the list of the available sub-commands, sorted by name
+func printCommands(w io.Writer) {
+	var names []string
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprint(w, "Commands:\n")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, subcommands[name].summary)
+	}
+}
+
+// newCommandFlagSet creates the flag set for a sub-command, with a usage message showing its syntax
+func newCommandFlagSet(name string, syntax string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s %s\n", os.Args[0], name, syntax)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+/*
+parseCommandArgs parses the flags of a sub-command, which can be interleaved with positional arguments.
+E.g.: 'startainer logs alpine -f splunk81' is equivalent to 'startainer logs -f alpine splunk81'.
+It returns the positional arguments, and the arguments following a "--", which are not parsed at all.
+*/
+func parseCommandArgs(fs *flag.FlagSet, args []string) (positional []string, passthrough []string) {
+	for i, arg := range args {
+		if arg == "--" {
+			passthrough = args[i+1:]
+			args = args[:i]
+			break
+		}
+	}
+	for {
+		// the flagset is configured with ExitOnError, thus parsing errors terminate the program
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	return positional, passthrough
+}
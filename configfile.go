@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// definitionNameRegex matches the names which can be used for definitions:
+// viper lowercases the keys, and uses dots to separate nested keys
+var definitionNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ValidDefinitionName returns an error if the name cannot be used for a new definition
+func ValidDefinitionName(name string) error {
+	if !definitionNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid definition name '%s': use only lowercase letters, digits, '-' and '_'", name)
+	}
+	if name == "settings" {
+		return fmt.Errorf("'settings' is reserved for the global configurations")
+	}
+	if _, ok := subcommands[name]; ok {
+		return fmt.Errorf("'%s' is the name of a command, and cannot be used for a definition", name)
+	}
+	if ConfigType(name) != CONFTYPEUNKNOWN {
+		return fmt.Errorf("a definition named '%s' already exists within the configuration file", name)
+	}
+	return nil
+}
+
+/*
+yamlNumberRegex matches the values starting as a number, which YAML might resolve as an integer, a float, an octal,
+a hexadecimal, an exponent, a sexagesimal or a timestamp, e.g. '0755', '1.0', '1e3', '0x1F', '.5', '8080:80' or '.inf'.
+*/
+var yamlNumberRegex = regexp.MustCompile(`^([-+]?\.?[0-9]|[-+]?\.(inf|Inf|INF)$|\.(nan|NaN|NAN)$)`)
+
+// yamlValue returns the value ready to be written within a YAML file, quoting it only if needed
+func yamlValue(value string) string {
+	plain := value != "" &&
+		!yamlNumberRegex.MatchString(value) &&
+		strings.TrimSpace(value) == value &&
+		!strings.ContainsAny(value, "\t\n\r\"'\\`") &&
+		!strings.ContainsAny(value[:1], "?:,[]{}#&*!|>%@") &&
+		!strings.Contains(value, ": ") && !strings.Contains(value, " #") &&
+		!strings.HasSuffix(value, ":") && !strings.HasPrefix(value, "- ") && value != "-" &&
+		!IsIn(strings.ToLower(value), []string{"true", "false", "yes", "no", "on", "off", "null", "~", "y", "n"})
+	if plain {
+		return value
+	}
+	// JSON strings are valid YAML double-quoted strings
+	quoted, _ := json.Marshal(value)
+	return string(quoted)
+}
+
+// yamlMap is a YAML mapping which keeps its keys in the order they are set.
+// Values can be strings, booleans, integers, lists of strings, lists of yamlMap and nested yamlMap.
+type yamlMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newYamlMap() *yamlMap {
+	return &yamlMap{values: map[string]interface{}{}}
+}
+
+// Set adds a key to the mapping, or replaces its value. Empty strings and lists are ignored.
+func (m *yamlMap) Set(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return
+		}
+	case []string:
+		if len(v) == 0 {
+			return
+		}
+	case []*yamlMap:
+		if len(v) == 0 {
+			return
+		}
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Get returns the value of a key, or nil if the key is not set
+func (m *yamlMap) Get(key string) interface{} {
+	return m.values[key]
+}
+
+// Append adds the items to the list of strings of a key
+func (m *yamlMap) Append(key string, items ...string) {
+	list, _ := m.values[key].([]string)
+	m.Set(key, append(list, items...))
+}
+
+// write writes the mapping as YAML text, indented by 'indent' levels of two spaces
+func (m *yamlMap) write(b *strings.Builder, indent int) {
+	prefix := strings.Repeat("  ", indent)
+	for _, key := range m.keys {
+		switch v := m.values[key].(type) {
+		case string:
+			fmt.Fprintf(b, "%s%s: %s\n", prefix, yamlValue(key), yamlValue(v))
+		case bool, int:
+			fmt.Fprintf(b, "%s%s: %v\n", prefix, yamlValue(key), v)
+		case []string:
+			fmt.Fprintf(b, "%s%s:\n", prefix, yamlValue(key))
+			for _, item := range v {
+				fmt.Fprintf(b, "%s  - %s\n", prefix, yamlValue(item))
+			}
+		case []*yamlMap:
+			fmt.Fprintf(b, "%s%s:\n", prefix, yamlValue(key))
+			for _, item := range v {
+				// the first key of the mapping follows the dash, the others are aligned to it
+				var item_b strings.Builder
+				item.write(&item_b, indent+2)
+				b.WriteString(prefix + "  - " + strings.TrimLeft(item_b.String(), " "))
+			}
+		case *yamlMap:
+			if len(v.keys) == 0 {
+				fmt.Fprintf(b, "%s%s: {}\n", prefix, yamlValue(key))
+			} else {
+				fmt.Fprintf(b, "%s%s:\n", prefix, yamlValue(key))
+				v.write(b, indent+1)
+			}
+		}
+	}
+}
+
+// String returns the YAML text of the mapping
+func (m *yamlMap) String() string {
+	var b strings.Builder
+	m.write(&b, 0)
+	return b.String()
+}
+
+// yamlDefinition is a definition to be written into the configuration file
+type yamlDefinition struct {
+	*yamlMap
+	name    string
+	comment string
+}
+
+func newYamlDefinition(name string, comment string) *yamlDefinition {
+	return &yamlDefinition{yamlMap: newYamlMap(), name: name, comment: comment}
+}
+
+// String returns the YAML text of the definition, formatted the same way as the sample configuration file
+func (def *yamlDefinition) String() string {
+	var b strings.Builder
+	if def.comment != "" {
+		fmt.Fprintf(&b, "# %s\n", def.comment)
+	}
+	fmt.Fprintf(&b, "%s:\n", def.name)
+	def.write(&b, 1)
+	return b.String()
+}
+
+/*
+AppendDefinition adds the definition at the end of the configuration file in use.
+The file is not parsed and re-written, so that its comments and ordering are preserved.
+*/
+func AppendDefinition(def *yamlDefinition) error {
+	configFile := viper.ConfigFileUsed()
+	content, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+	text := "\n" + def.String()
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		text = "\n" + text
+	}
+	f, err := os.OpenFile(configFile, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(text); err != nil {
+		return fmt.Errorf("impossible to write into config file '%s'. %s", configFile, err)
+	}
+	return nil
+}
+
+// topLevelKeyRegex matches the lines of a YAML file starting a top-level key, such as the name of a definition
+var topLevelKeyRegex = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^\s#'"-][^:]*)\s*:(\s|$)`)
+
+// readConfigLines returns the lines of the configuration file in use
+func readConfigLines() ([]string, error) {
+	content, err := ioutil.ReadFile(viper.ConfigFileUsed())
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n"), nil
+}
+
+/*
+definitionBlock finds a definition within the lines of the configuration file. The definition spans the lines [start, end):
+its key and its content, up to the next top-level key, without the blank and comment lines preceding that key.
+'comment' is the first of the comment lines directly preceding the key, which describe the definition, or 'start' if there are none.
+*/
+func definitionBlock(lines []string, name string) (comment int, start int, end int, err error) {
+	start = -1
+	for i, line := range lines {
+		match := topLevelKeyRegex.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		if start >= 0 {
+			end = i
+			break
+		}
+		if strings.Trim(match[1], `"'`) == name {
+			start = i
+		}
+	}
+	if start < 0 {
+		return 0, 0, 0, fmt.Errorf("no definition named '%s' within the configuration file '%s'", name, viper.ConfigFileUsed())
+	}
+	if end == 0 {
+		end = len(lines)
+	}
+	for end-1 > start && (strings.TrimSpace(lines[end-1]) == "" || strings.HasPrefix(lines[end-1], "#")) {
+		end--
+	}
+	for comment = start; comment > 0 && strings.HasPrefix(lines[comment-1], "#"); comment-- {
+	}
+	return comment, start, end, nil
+}
+
+/*
+WriteConfigLines replaces the content of the configuration file in use with the given lines, once they are verified to
+be valid YAML. The file is replaced at once, so that it is never left half-written.
+*/
+func WriteConfigLines(lines []string) error {
+	configFile := viper.ConfigFileUsed()
+	content := strings.Join(lines, "\n") + "\n"
+	check := viper.New()
+	check.SetConfigType("yaml")
+	if err := check.ReadConfig(strings.NewReader(content)); err != nil {
+		return fmt.Errorf("the configuration file would not be valid YAML anymore, it was not changed. %s", err)
+	}
+	if dryRun {
+		explain("would write the configuration file '%s'", configFile)
+		return nil
+	}
+	info, err := os.Stat(configFile)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(configFile), "."+filepath.Base(configFile)+"-*")
+	if err != nil {
+		return fmt.Errorf("impossible to write into config file '%s'. %s", configFile, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("impossible to write into config file '%s'. %s", configFile, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), configFile)
+}
+
+/*
+ValidateDefinitionText verifies the YAML text of a single definition, e.g. as edited by the user:
+it must contain only the definition 'name', which must be either a container or a compose definition.
+*/
+func ValidateDefinitionText(name string, text string) error {
+	check := viper.New()
+	check.SetConfigType("yaml")
+	if err := check.ReadConfig(strings.NewReader(text)); err != nil {
+		return fmt.Errorf("invalid YAML. %s", err)
+	}
+	for _, key := range check.AllKeys() {
+		if top := strings.SplitN(key, ".", 2)[0]; top != name {
+			return fmt.Errorf("only the definition '%s' can be edited, found '%s'", name, top)
+		}
+	}
+	switch {
+	case check.IsSet(name + ".compose"):
+	case check.IsSet(name + ".run"):
+		if _, err := parseRunArgs(check.GetStringSlice(name + ".run")); err != nil {
+			return fmt.Errorf("invalid 'run' configuration. %s", err)
+		}
+	default:
+		return fmt.Errorf("the definition '%s' has neither a 'run' nor a 'compose' configuration", name)
+	}
+	return nil
+}
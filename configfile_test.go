@@ -0,0 +1,29 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// TestYamlValueRoundTrip verifies that the values written by yamlValue are read back unchanged by viper
+func TestYamlValueRoundTrip(t *testing.T) {
+	values := []string{
+		"alpine", "chmod", "0755", "/f", "1.0", "1e3", "0x1F", "0o17", "0b101", "-1", "+1", ".5", "1_000",
+		"8080:80", "5353:53/udp", "2026-10-19", ".inf", "-.inf", ".NaN", "true", "no", "~", "null", "",
+		"-p=8080:80", "--name=web", "nginx:1.25", "a: b", "# comment", "value #1", "-", "- item", "key:",
+		"\"quoted\"", "it's", "tab\there", " spaced ", "*alias", "&anchor", "!tag", "%percent", "@at", "`cmd`",
+	}
+	def := newYamlDefinition("roundtrip", "")
+	def.Set("run", values)
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(strings.NewReader(def.String())); err != nil {
+		t.Fatalf("invalid YAML:\n%s\n%s", def.String(), err)
+	}
+	if got := v.GetStringSlice("roundtrip.run"); !reflect.DeepEqual(got, values) {
+		t.Errorf("values changed by the round trip:\n got %q\nwant %q\nYAML:\n%s", got, values, def.String())
+	}
+}
@@ -1,12 +1,41 @@
 package main
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+/*
+Controller manages the container or compose stack of a definition, with the same code paths used when
+invoking startainer from the command line. It is used by the long-running commands, such as 'ui',
+which must not terminate when an action fails: errors are returned rather than being fatal.
+*/
 type Controller interface {
 	Start(params ...string) error
 	Stop() error
+	Restart() error
 	Status() (status string, err error)
 	List() (configsText string, err error)
 }
 
+// NewController returns the controller of a definition. The target can reference specific services of a compose definition, e.g. 'composeexample:web'.
+func NewController(runtimeCmd string, target string) (Controller, error) {
+	definition, services, err := SplitTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	switch ConfigType(definition) {
+	case CONFTYPECONTAINER:
+		return NewContainerController(runtimeCmd, definition), nil
+	case CONFTYPECOMPOSE:
+		return NewComposeController(runtimeCmd, definition, ComposeServices(definition, services)), nil
+	default:
+		return nil, fmt.Errorf("impossible to discern type of configuration for '%s'", definition)
+	}
+}
+
 type containerController struct {
 	runtimeCmd string
 	container  string
@@ -20,18 +49,74 @@ func NewContainerController(runtimeCmd, containerName string) Controller {
 	return cc
 }
 
+// Start runs, starts or attaches to the container, as 'startainer <definition>' does
 func (cc containerController) Start(params ...string) error {
-	return nil
+	return ManageContainer(cc.runtimeCmd, cc.container, params)
 }
 
 func (cc containerController) Status() (status string, err error) {
-	return "", nil
+	return ContainerStatus(cc.runtimeCmd, cc.container, false)
 }
 
 func (cc containerController) Stop() error {
-	return nil
+	return StopDefinition(cc.runtimeCmd, cc.container, nil)
+}
+
+// Restart stops the container if it is running, then starts it again without asking for confirmation
+func (cc containerController) Restart() error {
+	return restartContainer(cc.runtimeCmd, cc.container, true)
 }
 
 func (cc containerController) List() (configsText string, err error) {
-	return "", nil
+	return strings.Join(containerConfigSections(cc.runtimeCmd, cc.container), "\n"), nil
+}
+
+type composeController struct {
+	runtimeCmd string
+	compose    string
+	services   []string
+}
+
+func NewComposeController(runtimeCmd, composeConfName string, services []string) Controller {
+	return composeController{
+		runtimeCmd: runtimeCmd,
+		compose:    composeConfName,
+		services:   services,
+	}
+}
+
+// Start brings up the services of the compose stack, or attaches to them, as 'startainer <definition>' does
+func (cc composeController) Start(params ...string) error {
+	return ManageCompose(cc.runtimeCmd, cc.compose, cc.services, params)
+}
+
+func (cc composeController) Status() (status string, err error) {
+	return ComposeStatus(cc.runtimeCmd, cc.compose, cc.services, false)
+}
+
+func (cc composeController) Stop() error {
+	return StopDefinition(cc.runtimeCmd, cc.compose, cc.services)
+}
+
+// Restart restarts the services of the compose stack without asking for confirmation
+func (cc composeController) Restart() error {
+	return restartCompose(cc.runtimeCmd, cc.compose, cc.services, true)
+}
+
+func (cc composeController) List() (configsText string, err error) {
+	files, err := ComposeFiles(cc.compose)
+	if err != nil {
+		return "", err
+	}
+	text := fmt.Sprintf("Compose files of the stack:\n    %s\n", strings.Join(files, "\n    "))
+	if len(cc.services) > 0 {
+		text += fmt.Sprintf("\nTargeted services:\n    %s\n", strings.Join(cc.services, "\n    "))
+	}
+	if viper.IsSet(cc.compose + ".up") {
+		text += fmt.Sprintf("\nUP configurations for the stack:\n    %s compose up\n    %s\n", cc.runtimeCmd, strings.Join(viper.GetStringSlice(cc.compose+".up"), "\n    "))
+	}
+	for _, section := range execConfigSections(cc.compose, "stack", cc.runtimeCmd+" compose exec") {
+		text += "\n" + section
+	}
+	return text, nil
 }
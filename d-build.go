@@ -0,0 +1,199 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// name of the file, within the state dir, tracking the images built by startainer
+const BUILDSSTATEFILE string = "builds.json"
+
+// buildState is what startainer remembers locally about an image it built
+type buildState struct {
+	LastBuild   time.Time `json:"last_build"`
+	Fingerprint string    `json:"fingerprint"`
+}
+
+// buildConfig is the content of the 'build' block of a container definition, with paths already expanded
+type buildConfig struct {
+	context    string
+	dockerfile string
+	args       []string // KEY=VALUE items
+	target     string
+	tag        string
+}
+
+// HasBuild returns true if the container definition specifies how to build its image
+func HasBuild(containerName string) bool {
+	return viper.IsSet(containerName + ".build")
+}
+
+// readBuildConfig reads the 'build' block of a container definition.
+// The tag defaults to the 'image' of the definition, the dockerfile to 'Dockerfile' within the context.
+func readBuildConfig(containerName string) (buildConfig, error) {
+	var conf buildConfig
+	var err error
+	prefix := containerName + ".build."
+	if conf.context, err = ExpandPath(viper.GetString(prefix + "context")); err != nil {
+		return conf, err
+	}
+	if conf.context == "" {
+		return conf, fmt.Errorf("no 'build.context' configured for '%s'", containerName)
+	}
+	if info, err := os.Stat(conf.context); err != nil || !info.IsDir() {
+		return conf, fmt.Errorf("build context '%s' of '%s' is not a folder", conf.context, containerName)
+	}
+
+	conf.dockerfile = viper.GetString(prefix + "dockerfile")
+	if conf.dockerfile == "" {
+		conf.dockerfile = "Dockerfile"
+	}
+	if strings.HasPrefix(conf.dockerfile, "~") {
+		conf.dockerfile, _ = ExpandPath(conf.dockerfile)
+	} else if !filepath.IsAbs(conf.dockerfile) {
+		// relative dockerfiles are relative to the build context
+		conf.dockerfile = filepath.Join(conf.context, conf.dockerfile)
+	}
+	if !FileExists(conf.dockerfile) {
+		return conf, fmt.Errorf("dockerfile '%s' of '%s' not found", conf.dockerfile, containerName)
+	}
+
+	// build args are a list of KEY=VALUE items, rather than a map, as viper would lowercase the keys of a map
+	conf.args = viper.GetStringSlice(prefix + "args")
+	conf.target = viper.GetString(prefix + "target")
+	conf.tag = viper.GetString(prefix + "tag")
+	if conf.tag == "" {
+		conf.tag = viper.GetString(containerName + ".image")
+	}
+	if conf.tag == "" {
+		return conf, fmt.Errorf("no 'build.tag' nor 'image' configured for '%s'", containerName)
+	}
+	return conf, nil
+}
+
+/*
+fingerprint computes a hash representing the build configuration, the dockerfile and the files within the build context.
+Files are represented by their path, size and modification time, so that large contexts do not need to be read.
+The '.git' folder is not considered.
+*/
+func (conf buildConfig) fingerprint() (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "target=%s\n", conf.target)
+	for _, arg := range conf.args {
+		fmt.Fprintf(h, "arg %s\n", arg)
+	}
+	dockerfile, err := ioutil.ReadFile(conf.dockerfile)
+	if err != nil {
+		return "", err
+	}
+	h.Write(dockerfile)
+
+	err = filepath.Walk(conf.context, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() {
+			rel, _ := filepath.Rel(conf.context, path)
+			fmt.Fprintf(h, "%s %d %d\n", filepath.ToSlash(rel), info.Size(), info.ModTime().UnixNano())
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("impossible to analyze build context '%s'. %s", conf.context, err)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+/*
+BuildNeeded checks whether the image of a container definition has to be built:
+either because it is missing, or because the dockerfile or the build context changed since the last build.
+Besides the decision, it returns a short textual reason for it.
+*/
+func BuildNeeded(containerManagerCmd string, containerName string, verbose bool) (build bool, reason string, err error) {
+	conf, err := readBuildConfig(containerName)
+	if err != nil {
+		return false, "", err
+	}
+	image_status, err := ImageStatus(containerManagerCmd, conf.tag, verbose)
+	if err != nil {
+		return false, "", err
+	}
+	if image_status == MISSING {
+		return true, "image is missing", nil
+	}
+	fingerprint, err := conf.fingerprint()
+	if err != nil {
+		return false, "", err
+	}
+	builds := make(map[string]buildState)
+	if err := readStateFile(BUILDSSTATEFILE, &builds); err != nil {
+		log.Printf("Warning: %s", err)
+	}
+	previous, ok := builds[conf.tag]
+	switch {
+	case !ok:
+		return true, "no previous build was recorded", nil
+	case previous.Fingerprint != fingerprint:
+		return true, fmt.Sprintf("dockerfile or build context changed since last build on %s", previous.LastBuild.Format("2006-01-02 15:04")), nil
+	default:
+		return false, "image is up to date", nil
+	}
+}
+
+// ImageBuild builds the image of a container definition according to its 'build' block,
+// and records the fingerprint of the build within the state dir.
+func ImageBuild(containerManagerCmd string, containerName string) error {
+	conf, err := readBuildConfig(containerName)
+	if err != nil {
+		return err
+	}
+	// compute the fingerprint before building, so that changes happening during the build trigger a new one
+	fingerprint, err := conf.fingerprint()
+	if err != nil {
+		return err
+	}
+
+	build_args := []string{"build", "--tag=" + conf.tag, "--file=" + conf.dockerfile}
+	for _, arg := range conf.args {
+		build_args = append(build_args, "--build-arg="+arg)
+	}
+	if conf.target != "" {
+		build_args = append(build_args, "--target="+conf.target)
+	}
+	build_args = append(build_args, conf.context)
+
+	log.Printf("Building image '%s' for '%s'", conf.tag, containerName)
+	cmd := forDefinition(exec.Command(containerManagerCmd, build_args...), containerName)
+	// the build output, including its progress written to stderr, is shown to the user
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	log.Printf("Build arguments are:\n  %s", strings.Join(cmd.Args, " "))
+	if err := runCommand(cmd); err != nil {
+		return fmt.Errorf("an error occurred when building image '%s'. Command line arguments were:\n  %s\n%s", conf.tag, strings.Join(cmd.Args, " "), err)
+	}
+	if dryRun {
+		return nil
+	}
+
+	builds := make(map[string]buildState)
+	if err := readStateFile(BUILDSSTATEFILE, &builds); err != nil {
+		log.Printf("Warning: %s", err)
+	}
+	builds[conf.tag] = buildState{LastBuild: time.Now(), Fingerprint: fingerprint}
+	if err := writeStateFile(BUILDSSTATEFILE, builds); err != nil {
+		log.Printf("Warning: impossible to record the build of image '%s'. %s", conf.tag, err)
+	}
+	return nil
+}
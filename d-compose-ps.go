@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+/*
+composePS executes 'compose ps' for the targeted services of a compose definition, and returns the containers it lists.
+The executed command and its stderr are returned as well, to allow the caller to analyze errors.
+
+Compose v1 ('docker-compose' before v2) does not support '--format json': in that case, the IDs of the containers
+are retrieved with 'compose ps -q', and the containers are analyzed with 'container inspect'.
+*/
+func composePS(containerManagerCmd string, composeConfName string, services []string) (instances []dockerComposePSJsonOutput, cmd *exec.Cmd, errb *bytes.Buffer, err error) {
+	var outb bytes.Buffer
+	errb = &bytes.Buffer{}
+	cmd, err = composeCommand(containerManagerCmd, composeConfName, append([]string{"ps", "-a", "--format", "json"}, services...)...)
+	if err != nil {
+		return nil, nil, errb, err
+	}
+	cmd.Stdout = &outb
+	cmd.Stderr = errb
+	if err = cmd.Run(); err != nil {
+		stderr := strings.ToLower(errb.String())
+		if _, ok := err.(*exec.ExitError); ok && (strings.Contains(stderr, "no such option") || strings.Contains(stderr, "unknown flag")) {
+			return composePSLegacy(containerManagerCmd, composeConfName, services)
+		}
+		return nil, cmd, errb, err
+	}
+	if instances, err = parseComposePS(outb.Bytes()); err != nil {
+		return nil, cmd, errb, fmt.Errorf("impossible to read output of '%s'. %s", strings.Join(cmd.Args, " "), err)
+	}
+	return instances, cmd, errb, nil
+}
+
+// composePSLegacy lists the containers of a compose definition for compose versions not supporting 'ps --format json'
+func composePSLegacy(containerManagerCmd string, composeConfName string, services []string) (instances []dockerComposePSJsonOutput, cmd *exec.Cmd, errb *bytes.Buffer, err error) {
+	var outb bytes.Buffer
+	errb = &bytes.Buffer{}
+	cmd, err = composeCommand(containerManagerCmd, composeConfName, append([]string{"ps", "-a", "-q"}, services...)...)
+	if err != nil {
+		return nil, nil, errb, err
+	}
+	cmd.Stdout = &outb
+	cmd.Stderr = errb
+	if err = cmd.Run(); err != nil {
+		return nil, cmd, errb, err
+	}
+	ids := strings.Fields(outb.String())
+	if len(ids) == 0 {
+		return nil, cmd, errb, nil
+	}
+
+	outb.Reset()
+	cmd = exec.Command(containerManagerCmd, append([]string{"container", "inspect"}, ids...)...)
+	cmd.Stdout = &outb
+	cmd.Stderr = errb
+	if err = cmd.Run(); err != nil {
+		return nil, cmd, errb, err
+	}
+	if instances, err = parseContainerInspect(outb.Bytes()); err != nil {
+		return nil, cmd, errb, fmt.Errorf("impossible to read output of '%s'. %s", strings.Join(cmd.Args, " "), err)
+	}
+	return instances, cmd, errb, nil
+}
+
+/*
+parseComposePS reads the output of 'compose ps --format json', which differs depending on the compose implementation:
+  - docker compose before v2.21: a JSON array of objects
+  - docker compose since v2.21: one JSON object per line (NDJSON)
+  - podman-compose: the JSON array of 'podman ps', where the service is only available within the labels
+*/
+func parseComposePS(output []byte) ([]dockerComposePSJsonOutput, error) {
+	var instances []dockerComposePSJsonOutput
+	output = bytes.TrimSpace(output)
+	if len(output) == 0 {
+		return instances, nil
+	}
+	var objects []map[string]interface{}
+	if output[0] == '[' {
+		if err := json.Unmarshal(output, &objects); err != nil {
+			return nil, err
+		}
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(output))
+		for {
+			var object map[string]interface{}
+			if err := decoder.Decode(&object); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			objects = append(objects, object)
+		}
+	}
+	for _, object := range objects {
+		instances = append(instances, composeInstanceFromJSON(object))
+	}
+	return instances, nil
+}
+
+// composeInstanceFromJSON normalizes an object listed by 'compose ps --format json'
+func composeInstanceFromJSON(object map[string]interface{}) dockerComposePSJsonOutput {
+	instance := dockerComposePSJsonOutput{
+		ID:      jsonString(object, "ID", "Id"),
+		Name:    jsonString(object, "Name"),
+		Service: jsonString(object, "Service"),
+		State:   strings.ToLower(jsonString(object, "State")),
+		Status:  jsonString(object, "Status"),
+		Health:  strings.ToLower(jsonString(object, "Health")),
+	}
+	if exit_code, ok := object["ExitCode"].(float64); ok {
+		instance.ExitCode = int(exit_code)
+	}
+	// podman: names are a list, and the service is only available as label
+	if names, ok := object["Names"].([]interface{}); ok && instance.Name == "" && len(names) > 0 {
+		instance.Name, _ = names[0].(string)
+	}
+	if labels, ok := object["Labels"].(map[string]interface{}); ok && instance.Service == "" {
+		instance.Service, _ = labels["com.docker.compose.service"].(string)
+	}
+	// podman: the health is only reported within the status, e.g. "Up 5 minutes (unhealthy)"
+	if instance.Health == "" && strings.Contains(instance.Status, "(unhealthy)") {
+		instance.Health = "unhealthy"
+	}
+	return instance
+}
+
+// parseContainerInspect reads the output of 'container inspect' for containers created by compose
+func parseContainerInspect(output []byte) ([]dockerComposePSJsonOutput, error) {
+	var objects []struct {
+		ID    string `json:"Id"`
+		Name  string `json:"Name"`
+		State struct {
+			Status   string `json:"Status"`
+			ExitCode int    `json:"ExitCode"`
+			Health   struct {
+				Status string `json:"Status"`
+			} `json:"Health"`
+		} `json:"State"`
+		Config struct {
+			Labels map[string]string `json:"Labels"`
+		} `json:"Config"`
+	}
+	if err := json.Unmarshal(output, &objects); err != nil {
+		return nil, err
+	}
+	var instances []dockerComposePSJsonOutput
+	for _, object := range objects {
+		instances = append(instances, dockerComposePSJsonOutput{
+			ID:       object.ID,
+			Name:     strings.TrimPrefix(object.Name, "/"),
+			Service:  object.Config.Labels["com.docker.compose.service"],
+			State:    object.State.Status,
+			Status:   object.State.Status,
+			Health:   object.State.Health.Status,
+			ExitCode: object.State.ExitCode,
+		})
+	}
+	return instances, nil
+}
+
+// jsonString returns the first of the keys of the object having a string value
+func jsonString(object map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := object[key].(string); ok {
+			return value
+		}
+	}
+	return ""
+}
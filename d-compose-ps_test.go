@@ -0,0 +1,165 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// composePSArray is the output of 'docker compose ps -a --format json' before compose v2.21
+const composePSArray = `[{"ID":"3f1c2a","Name":"example-web-1","Command":"nginx -g 'daemon off;'","Project":"example","Service":"web","State":"running","Health":"","ExitCode":0,"Publishers":[{"URL":"0.0.0.0","TargetPort":80,"PublishedPort":8080,"Protocol":"tcp"}]},{"ID":"9b7e4d","Name":"example-db-1","Command":"docker-entrypoint.sh postgres","Project":"example","Service":"db","State":"exited","Health":"","ExitCode":1,"Publishers":null}]`
+
+// composePSNDJSON is the output of 'docker compose ps -a --format json' since compose v2.21: one object per line
+const composePSNDJSON = `{"ID":"3f1c2a","Name":"example-web-1","Project":"example","Service":"web","State":"running","Status":"Up 2 minutes (healthy)","Health":"healthy","ExitCode":0}
+{"ID":"9b7e4d","Name":"example-db-1","Project":"example","Service":"db","State":"running","Status":"Up 2 minutes (unhealthy)","Health":"unhealthy","ExitCode":0}
+`
+
+// composePSPodman is the output of 'podman-compose ps -a --format json', which is the one of 'podman ps'
+const composePSPodman = `[
+  {
+    "Id": "5d0e8f",
+    "Names": ["example_web_1"],
+    "Image": "docker.io/library/nginx:latest",
+    "State": "running",
+    "Status": "Up 5 minutes (unhealthy)",
+    "ExitCode": 0,
+    "Labels": {
+      "com.docker.compose.project": "example",
+      "com.docker.compose.service": "web"
+    }
+  },
+  {
+    "Id": "7a2c91",
+    "Names": ["example_worker_1"],
+    "Image": "docker.io/library/busybox:latest",
+    "State": "exited",
+    "Status": "Exited (137) 1 minute ago",
+    "ExitCode": 137,
+    "Labels": {
+      "com.docker.compose.project": "example",
+      "com.docker.compose.service": "worker"
+    }
+  }
+]`
+
+// containerInspectCompose is the output of 'container inspect' for containers created by compose v1
+const containerInspectCompose = `[
+  {
+    "Id": "3f1c2a",
+    "Name": "/example_web_1",
+    "State": {"Status": "running", "Running": true, "ExitCode": 0, "Health": {"Status": "healthy", "FailingStreak": 0}},
+    "Config": {"Labels": {"com.docker.compose.project": "example", "com.docker.compose.service": "web"}}
+  },
+  {
+    "Id": "9b7e4d",
+    "Name": "/example_db_1",
+    "State": {"Status": "exited", "Running": false, "ExitCode": 2},
+    "Config": {"Labels": {"com.docker.compose.project": "example", "com.docker.compose.service": "db"}}
+  }
+]`
+
+func TestParseComposePS(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []dockerComposePSJsonOutput
+	}{
+		{
+			name:   "array",
+			output: composePSArray,
+			want: []dockerComposePSJsonOutput{
+				{ID: "3f1c2a", Name: "example-web-1", Service: "web", State: "running"},
+				{ID: "9b7e4d", Name: "example-db-1", Service: "db", State: "exited", ExitCode: 1},
+			},
+		},
+		{
+			name:   "ndjson",
+			output: composePSNDJSON,
+			want: []dockerComposePSJsonOutput{
+				{ID: "3f1c2a", Name: "example-web-1", Service: "web", State: "running", Status: "Up 2 minutes (healthy)", Health: "healthy"},
+				{ID: "9b7e4d", Name: "example-db-1", Service: "db", State: "running", Status: "Up 2 minutes (unhealthy)", Health: "unhealthy"},
+			},
+		},
+		{
+			name:   "podman-compose",
+			output: composePSPodman,
+			want: []dockerComposePSJsonOutput{
+				{ID: "5d0e8f", Name: "example_web_1", Service: "web", State: "running", Status: "Up 5 minutes (unhealthy)", Health: "unhealthy"},
+				{ID: "7a2c91", Name: "example_worker_1", Service: "worker", State: "exited", Status: "Exited (137) 1 minute ago", ExitCode: 137},
+			},
+		},
+		{
+			name:   "empty",
+			output: "\n",
+			want:   nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseComposePS([]byte(test.output))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseComposePSInvalid(t *testing.T) {
+	for _, output := range []string{`[{"ID":`, "{\"ID\":\"3f1c2a\"}\nnot json"} {
+		if _, err := parseComposePS([]byte(output)); err == nil {
+			t.Errorf("no error for invalid output %q", output)
+		}
+	}
+}
+
+func TestParseContainerInspect(t *testing.T) {
+	want := []dockerComposePSJsonOutput{
+		{ID: "3f1c2a", Name: "example_web_1", Service: "web", State: "running", Status: "running", Health: "healthy"},
+		{ID: "9b7e4d", Name: "example_db_1", Service: "db", State: "exited", Status: "exited", ExitCode: 2},
+	}
+	got, err := parseContainerInspect([]byte(containerInspectCompose))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestAggregateComposeStatus(t *testing.T) {
+	running := dockerComposePSJsonOutput{Service: "web", State: "running"}
+	unhealthy := dockerComposePSJsonOutput{Service: "web", State: "running", Health: "unhealthy"}
+	restarting := dockerComposePSJsonOutput{Service: "worker", State: "restarting"}
+	stopped := dockerComposePSJsonOutput{Service: "db", State: "exited"}
+	failed := dockerComposePSJsonOutput{Service: "db", State: "exited", ExitCode: 3}
+
+	tests := []struct {
+		name      string
+		instances []dockerComposePSJsonOutput
+		services  []string
+		want      string
+	}{
+		{"no container", nil, nil, MISSING},
+		{"no container for targeted services", nil, []string{"web"}, MISSING},
+		{"all running", []dockerComposePSJsonOutput{running, {Service: "db", State: "running"}}, nil, RUNNING},
+		{"one running, one stopped", []dockerComposePSJsonOutput{running, stopped}, nil, PARTIAL},
+		{"targeted service without container", []dockerComposePSJsonOutput{running}, []string{"web", "db"}, PARTIAL},
+		{"targeted service running", []dockerComposePSJsonOutput{running}, []string{"web"}, RUNNING},
+		{"restarting", []dockerComposePSJsonOutput{running, restarting}, nil, RESTARTING},
+		{"unhealthy", []dockerComposePSJsonOutput{unhealthy, {Service: "db", State: "running"}}, nil, UNHEALTHY},
+		{"unhealthy and stopped", []dockerComposePSJsonOutput{unhealthy, stopped}, nil, PARTIAL},
+		{"all stopped", []dockerComposePSJsonOutput{stopped, {Service: "web", State: "exited"}}, nil, STOPPED},
+		{"created", []dockerComposePSJsonOutput{{Service: "web", State: "created"}}, nil, STOPPED},
+		{"exited with error", []dockerComposePSJsonOutput{stopped, failed}, nil, ExitedStatus(3)},
+		{"running and exited with error", []dockerComposePSJsonOutput{running, failed}, nil, PARTIAL},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := aggregateComposeStatus(test.instances, test.services); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
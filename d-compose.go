@@ -3,7 +3,7 @@ package main
 import (
 	"bytes"
 	_ "embed"
-	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"os/exec"
@@ -17,79 +17,371 @@ import (
 // and check for status of the containers defined within the compose file
 // by default, the JSON unmashaler ignores the values present within JSON but not in the struct
 type dockerComposePSJsonOutput struct {
-	ID     string `json:"ID"`
-	Name   string `json:"Name"`
-	State  string `json:"State"`
-	Status string `json:"Status"`
+	ID       string `json:"ID"`
+	Name     string `json:"Name"`
+	Service  string `json:"Service"`
+	State    string `json:"State"`
+	Status   string `json:"Status"`
+	Health   string `json:"Health"`
+	ExitCode int    `json:"ExitCode"`
 }
 
-func ManageCompose(containerManagerCmd string, composeConfName string, additionalArgs []string) {
+// status translates the state of a container of a compose stack into a status
+func (instance dockerComposePSJsonOutput) status() string {
+	switch instance.State {
+	case "running":
+		if instance.Health == "unhealthy" {
+			return UNHEALTHY
+		}
+		return RUNNING
+	case "restarting":
+		return RESTARTING
+	}
+	if instance.ExitCode != 0 {
+		return ExitedStatus(instance.ExitCode)
+	}
+	return STOPPED
+}
+
+/*
+aggregateComposeStatus computes the status of a compose stack out of the ones of its containers:
+  - MISSING: no containers exist
+  - RESTARTING: at least one container is restarting
+  - PARTIAL: some containers are running, some are not. Targeted services without a container count as not running
+  - UNHEALTHY: all the containers are running, but at least one is unhealthy
+  - RUNNING: all the containers are running
+  - EXITED(code): no container is running, and at least one exited with a non-zero code
+  - STOPPED: no container is running
+*/
+func aggregateComposeStatus(instances []dockerComposePSJsonOutput, services []string) string {
+	if len(instances) == 0 {
+		return MISSING
+	}
+	running, not_running := 0, 0
+	restarting, unhealthy := false, false
+	exited := ""
+	for _, instance := range instances {
+		status := instance.status()
+		switch {
+		case status == RESTARTING:
+			restarting = true
+		case status == RUNNING || status == UNHEALTHY:
+			running++
+			unhealthy = unhealthy || status == UNHEALTHY
+		default:
+			not_running++
+			if IsExited(status) && exited == "" {
+				exited = status
+			}
+		}
+	}
+	// some of the targeted services might not have any container yet
+	for _, service := range services {
+		found := false
+		for _, instance := range instances {
+			found = found || instance.Service == service
+		}
+		if !found {
+			not_running++
+		}
+	}
+	switch {
+	case restarting:
+		return RESTARTING
+	case running > 0 && not_running > 0:
+		return PARTIAL
+	case running > 0 && unhealthy:
+		return UNHEALTHY
+	case running > 0:
+		return RUNNING
+	case exited != "":
+		return exited
+	default:
+		return STOPPED
+	}
+}
+
+// ComposeServices returns the services of a compose definition which are targeted:
+// the ones explicitly provided (e.g. 'composeexample:web,db'), otherwise the ones listed within the 'services' config.
+// An empty list means the whole stack.
+func ComposeServices(composeConfName string, services []string) []string {
+	if len(services) > 0 {
+		return services
+	}
+	return viper.GetStringSlice(composeConfName + ".services")
+}
+
+// describeCompose returns a textual description of a compose definition and its targeted services, to be used within log messages
+func describeCompose(composeConfName string, services []string) string {
+	if len(services) == 0 {
+		return fmt.Sprintf("compose stack '%s'", composeConfName)
+	}
+	return fmt.Sprintf("service(s) '%s' of compose stack '%s'", strings.Join(services, ", "), composeConfName)
+}
+
+/*
+ManageCompose brings the targeted services of a compose definition to the running state, depending on their status:
+the services not running are started with 'compose up', and a session is attached with 'compose exec' if all of them are running.
+*/
+func ManageCompose(containerManagerCmd string, composeConfName string, services []string, additionalArgs []string) error {
 	//log.Printf("Retrieving information about container '%s'", containerName)
-	status, err := ComposeStatus(containerManagerCmd, composeConfName, true)
+	status, err := ComposeStatus(containerManagerCmd, composeConfName, services, true)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	if IsExited(status) {
+		log.Printf("The %s %s", describeCompose(composeConfName, services), styleStatus(status))
+		status = STOPPED
+	} else if status == UNHEALTHY {
+		log.Printf("The %s is running, but %s", describeCompose(composeConfName, services), styleStatus(status))
+		status = RUNNING
+	}
+	switch status {
+	case MISSING, STOPPED:
+		explain("the %s is %s: it will be started with 'compose up'", describeCompose(composeConfName, services), status)
+	case PARTIAL:
+		explain("the %s is %s: the services not running will be started with 'compose up'", describeCompose(composeConfName, services), status)
+	case RUNNING:
+		if _, ok, _ := composeExecArgs(composeConfName, services); ok {
+			explain("the %s is %s: a session will be attached with 'compose exec'", describeCompose(composeConfName, services), status)
+		} else {
+			explain("the %s is %s: nothing to do", describeCompose(composeConfName, services), status)
+		}
 	}
 	switch status {
 	case COMPOSEFILENOTFOUND:
-		log.Fatalf("Configuration file for compose stack '%s' not found", composeConfName)
+		return fmt.Errorf("configuration file for compose stack '%s' not found", composeConfName)
+	case RESTARTING:
+		return fmt.Errorf("the %s is %s. Wait for it to be running, or use 'rm' to reset it", describeCompose(composeConfName, services), styleStatus(status))
+	case PARTIAL:
+		// only some of the services are running: "up" the other ones
+		to_start, err := ComposeServicesToStart(containerManagerCmd, composeConfName, services)
+		if err != nil {
+			return err
+		}
+		log.Printf("The %s is %s", describeCompose(composeConfName, services), styleStatus(status))
+		return ComposeUp(containerManagerCmd, composeConfName, to_start, additionalArgs, viper.GetString(composeConfName+".message"))
 	case MISSING:
 		// the containers for the compose file are stopped or missing to "up"
-		ComposeUp(containerManagerCmd, composeConfName, viper.GetString(composeConfName+".message"))
+		return ComposeUp(containerManagerCmd, composeConfName, services, additionalArgs, viper.GetString(composeConfName+".message"))
 	case STOPPED:
 		// the containers for the compose file are stopped or missing to "up"
-		ComposeUp(containerManagerCmd, composeConfName, viper.GetString(composeConfName+".message"))
+		return ComposeUp(containerManagerCmd, composeConfName, services, additionalArgs, viper.GetString(composeConfName+".message"))
 	case RUNNING:
-		log.Printf("The compose stack '%s' is already running", composeConfName)
+		exec_args, ok, err := composeExecArgs(composeConfName, services)
+		if err != nil {
+			return err
+		}
+		if ok && len(exec_args) == 1 {
+			// no 'exec' config: attach to the first shell available within the service
+			shell, err := probeComposeShell(containerManagerCmd, composeConfName, exec_args[0])
+			if err != nil {
+				return err
+			}
+			exec_args = append(exec_args, shell)
+		}
+		if ok {
+			return ComposeExec(containerManagerCmd, composeConfName, exec_args)
+		}
+		log.Printf("The %s is already running", describeCompose(composeConfName, services))
 	}
+	return nil
 }
 
-func ComposeStatus(containerManagerCmd string, composeConfName string, verbose bool) (status string, err error) {
-	var outb, errb bytes.Buffer
-	compose := viper.GetString(composeConfName + ".compose")
-
-	fullpath, err := ExpandPath(compose)
+/*
+composeExecArgs returns the parameters for 'compose exec' to be used when the compose stack is already running.
+These are taken from the 'exec' config (or the profile selected with '-as') of the compose definition, whose first non-flag
+item is the service to attach to. If a single service is targeted, it replaces the one within the 'exec' config.
+If no 'exec' config is present, but a single service is targeted, only '<service>' is returned: the shell has to be probed.
+The second return value is false if no 'compose exec' has to be performed.
+*/
+func composeExecArgs(composeConfName string, services []string) ([]string, bool, error) {
+	exec_args, found, err := execConfig(composeConfName)
 	if err != nil {
-		log.Printf("Impossible to expand path of file %s", compose)
-		return ERROR, err
+		return nil, false, err
 	}
-	if !FileExists(fullpath) {
-		return COMPOSEFILENOTFOUND, nil
+	if !found {
+		if len(services) == 1 {
+			return []string{services[0]}, true, nil
+		}
+		return nil, false, nil
 	}
+	if i := composeExecServiceIndex(exec_args); len(services) == 1 && i >= 0 {
+		exec_args[i] = services[0]
+	}
+	return exec_args, true, nil
+}
 
-	composeDir := filepath.Dir(fullpath)
-	composeFile := filepath.Base(compose)
+// composeExecValueFlags are the options of 'compose exec' followed by a value, e.g. '-u root'
+var composeExecValueFlags = []string{"-u", "--user", "-e", "--env", "-w", "--workdir", "--index"}
 
-	if verbose {
-		log.Printf("Retrieving information about compose '%s', '%s'", composeConfName, compose)
+// composeExecServiceIndex returns the index of the service within the parameters for 'compose exec', -1 if there is none
+func composeExecServiceIndex(exec_args []string) int {
+	for i := 0; i < len(exec_args); i++ {
+		arg := exec_args[i]
+		if !strings.HasPrefix(arg, "-") {
+			return i
+		}
+		if IsIn(arg, composeExecValueFlags) {
+			// skip the value of the option
+			i++
+		}
+	}
+	return -1
+}
+
+/*
+composeBaseCommand returns the executable and the initial parameters used to execute compose commands.
+By default, this is '<runtime> compose'. A different one can be set within 'settings.compose_cmd', e.g.:
+'docker compose', 'docker-compose' (compose v1) or 'podman-compose'.
+*/
+func composeBaseCommand(containerManagerCmd string) []string {
+	if compose_cmd := strings.Fields(viper.GetString("settings.compose_cmd")); len(compose_cmd) > 0 {
+		return compose_cmd
+	}
+	return []string{containerManagerCmd, "compose"}
+}
+
+/*
+ComposeFiles returns the compose files of a compose definition, with '~' and '.' expanded.
+The 'compose' config can be either the path of a single file, or a list of paths, e.g. a compose file and its overrides.
+Relative paths of the files following the first one are relative to the folder of the first file.
+*/
+func ComposeFiles(composeConfName string) ([]string, error) {
+	var files []string
+	for i, compose := range ConfigStringList(composeConfName + ".compose") {
+		if i > 0 {
+			files = append(files, pathRelativeTo(compose, filepath.Dir(files[0])))
+			continue
+		}
+		fullpath, err := ExpandPath(compose)
+		if err != nil {
+			return nil, fmt.Errorf("impossible to expand path of file '%s'. %s", compose, err)
+		}
+		files = append(files, fullpath)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no 'compose' file configured for '%s'", composeConfName)
 	}
-	cmd := exec.Command(containerManagerCmd, "compose", "-f", composeFile, "ps", "-a", "--format", "json")
+	return files, nil
+}
+
+// pathRelativeTo expands '~' within a path, and makes relative paths relative to the given folder
+func pathRelativeTo(path string, dir string) string {
+	if strings.HasPrefix(path, "~") {
+		path, _ = ExpandPath(path)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return path
+}
+
+/*
+composeProjectArgs returns the parameters identifying the compose project of a definition, which are provided
+to all the compose commands, so that status checks look at the same project which was started:
+the compose files, 'project_name', 'env_file' (a path or a list of paths) and 'profiles'.
+The first compose file is referenced by its name, as commands are executed within its folder.
+*/
+func composeProjectArgs(composeConfName string, files []string) []string {
+	compose_dir := filepath.Dir(files[0])
+	project_args := []string{"-f", filepath.Base(files[0])}
+	for _, file := range files[1:] {
+		project_args = append(project_args, "-f", file)
+	}
+	if project_name := viper.GetString(composeConfName + ".project_name"); project_name != "" {
+		project_args = append(project_args, "--project-name", project_name)
+	}
+	for _, env_file := range ConfigStringList(composeConfName + ".env_file") {
+		project_args = append(project_args, "--env-file", pathRelativeTo(env_file, compose_dir))
+	}
+	for _, profile := range ConfigStringList(composeConfName + ".profiles") {
+		project_args = append(project_args, "--profile", profile)
+	}
+	return project_args
+}
+
+// composeCommand prepares the execution of a "compose" command for the given compose definition.
+// The command is executed within the folder where the (first) compose file is contained.
+func composeCommand(containerManagerCmd string, composeConfName string, args ...string) (*exec.Cmd, error) {
+	files, err := ComposeFiles(composeConfName)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if !FileExists(file) {
+			return nil, fmt.Errorf("compose file not found '%s'", file)
+		}
+	}
+	base := composeBaseCommand(containerManagerCmd)
+	compose_args := append(append(base[1:], composeProjectArgs(composeConfName, files)...), args...)
+	cmd := exec.Command(base[0], compose_args...)
 	// execute the command in the folder where the compose-file is contained.
-	cmd.Dir = composeDir
+	cmd.Dir = filepath.Dir(files[0])
+	return cmd, nil
+}
 
-	cmd.Stdout = &outb
-	cmd.Stderr = &errb
-	err = cmd.Run()
-	switch err.(type) {
-	case nil:
-		var compose_output []dockerComposePSJsonOutput
-		err = json.Unmarshal(outb.Bytes(), &compose_output)
+/*
+ComposeServicesToStart returns the targeted services of a compose definition which do not have a running container.
+If no services are targeted, all the services defined within the compose file are considered.
+*/
+func ComposeServicesToStart(containerManagerCmd string, composeConfName string, services []string) ([]string, error) {
+	instances, _, _, err := composePS(containerManagerCmd, composeConfName, services)
+	if err != nil {
+		return nil, err
+	}
+	if len(services) == 0 {
+		var outb, errb bytes.Buffer
+		cmd, err := composeCommand(containerManagerCmd, composeConfName, "config", "--services")
 		if err != nil {
-			return ERROR, err
+			return nil, err
 		}
-		if len(compose_output) == 0 {
-			return MISSING, nil
+		cmd.Stdout = &outb
+		cmd.Stderr = &errb
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("impossible to list the services of compose stack '%s'. %s%s", composeConfName, errb.String(), err)
 		}
-		for _, instance := range compose_output {
-			if instance.State != "running" {
-				return STOPPED, nil
+		services = strings.Fields(outb.String())
+	}
+	var to_start []string
+	for _, service := range services {
+		running := false
+		for _, instance := range instances {
+			if instance.Service == service && IsRunning(instance.status()) {
+				running = true
 			}
 		}
-		return RUNNING, nil
+		if !running {
+			to_start = append(to_start, service)
+		}
+	}
+	return to_start, nil
+}
+
+func ComposeStatus(containerManagerCmd string, composeConfName string, services []string, verbose bool) (status string, err error) {
+	defer func() { resolveStatus(composeConfName, status) }()
+	files, err := ComposeFiles(composeConfName)
+	if err != nil {
+		log.Printf("Impossible to expand path of compose files of '%s'", composeConfName)
+		return ERROR, err
+	}
+	for _, file := range files {
+		if !FileExists(file) {
+			return COMPOSEFILENOTFOUND, nil
+		}
+	}
+
+	if verbose {
+		log.Printf("Retrieving information about %s, '%s'", describeCompose(composeConfName, services), strings.Join(files, "', '"))
+	}
+	compose_output, cmd, errb, err := composePS(containerManagerCmd, composeConfName, services)
+	switch err.(type) {
+	case nil:
+		return aggregateComposeStatus(compose_output, services), nil
 	case *exec.Error:
 		// check if the error was raised at the system level, such as if container manager is not installed.
 		log.Printf("System-error occurred when executing '%s compose ps'. Command line arguments were:\n  %s", containerManagerCmd, strings.Join(cmd.Args, " "))
 		log.Print(errb.String())
-		log.Fatal(err)
 	case *exec.ExitError:
 		// check if the error was raised at the command level, such as if command failed.
 		exitError, _ := err.(*exec.ExitError)
@@ -111,40 +403,36 @@ func ComposeStatus(containerManagerCmd string, composeConfName string, verbose b
 	return ERROR, err
 }
 
-func ComposeUp(containerManagerCmd string, composeConfName string, message string) {
-	log.Printf("Starting compose stack '%s'", composeConfName)
-
-	var errb bytes.Buffer
-	compose := viper.GetString(composeConfName + ".compose")
+func ComposeUp(containerManagerCmd string, composeConfName string, services []string, additionalArgs []string, message string) error {
+	log.Printf("Starting %s", describeCompose(composeConfName, services))
 
-	fullpath, err := ExpandPath(compose)
+	var errb stderrTail
+	// parameters of the 'up' config, followed by the ones provided by the user on the command line, then the services
+	up_args := append([]string{"up"}, viper.GetStringSlice(composeConfName+".up")...)
+	up_args = append(append(up_args, additionalArgs...), services...)
+	cmd, err := composeCommand(containerManagerCmd, composeConfName, up_args...)
 	if err != nil {
-		log.Fatalf("Impossible to expand path of file '%s'", compose)
-		return
+		return err
 	}
-	if !FileExists(fullpath) {
-		log.Fatalf("Compose file not found '%s'", fullpath)
-		return
+	forDefinition(cmd, composeConfName)
+	var onInterrupt func()
+	if !IsIn("-d", up_args) && !IsIn("--detach", up_args) && !IsIn("--wait", up_args) {
+		if onInterrupt, err = interruptAction(containerManagerCmd, composeConfName, services); err != nil {
+			return err
+		}
 	}
-
-	composeDir := filepath.Dir(fullpath)
-	composeFile := filepath.Base(compose)
-
-	cmd := exec.Command(containerManagerCmd, "compose", "-f", composeFile, "up")
-	// execute the command in the folder where the compose-file is contained.
-	cmd.Dir = composeDir
 	// Redirect all input and output of the parent to the child process
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	// this is used to be able to read the stderr of the container manager command
-	cmd.Stderr = &errb
+	teeStderr(cmd, &errb)
 
 	log.Printf("Compose startup arguments are:\n  %s", strings.Join(cmd.Args, " "))
 	if message != "" {
 		log.Print(blue(message))
 	}
 
-	err = cmd.Run()
+	err = runInterruptible(cmd, onInterrupt)
 	switch err.(type) {
 	case nil:
 		// program terminates here in best case
@@ -152,8 +440,7 @@ func ComposeUp(containerManagerCmd string, composeConfName string, message strin
 	case *exec.Error:
 		// check if the error was raised at the system level, such as if docker compose is not installed.
 		log.Printf("An error occurred when starting compose stack. Command line arguments were:\n  %s", strings.Join(cmd.Args, " "))
-		log.Print(errb.String())
-		log.Fatal(err)
+		return err
 	case *exec.ExitError:
 		// this is raised id the executed command does not return 0
 		exitError, _ := err.(*exec.ExitError)
@@ -164,8 +451,124 @@ func ComposeUp(containerManagerCmd string, composeConfName string, message strin
 			// the container is already running, try using the exec command
 			ContainerExec(containerManagerCmd, containerName)
 		} else { */
-		log.Printf("Unexpected error by executing '%s compose up'. Exit code is %d", containerManagerCmd, exitError.ExitCode())
-		log.Print(errb.String())
-		log.Fatal(exitError)
+		// when interrupted, the exit code is expected: the summary is printed out by exitOnError
+		if hint := startFailureHint(composeConfName, errb.String()); hint != "" {
+			log.Print(red(hint))
+		} else if interruption() == nil {
+			log.Printf("Unexpected error by executing '%s compose up'. Exit code is %d", containerManagerCmd, exitError.ExitCode())
+		}
+		return exitError
+	}
+	return nil
+}
+
+// ComposePull pulls the images of the services defined within the compose file of a definition.
+// If verbose is true, the output of the pull is shown to the user.
+func ComposePull(containerManagerCmd string, composeConfName string, services []string, verbose bool) error {
+	var outb bytes.Buffer
+	var errb stderrTail
+	cmd, err := composeCommand(containerManagerCmd, composeConfName, append([]string{"pull"}, services...)...)
+	if err != nil {
+		return err
+	}
+	forDefinition(cmd, composeConfName)
+	if verbose {
+		// compose writes the progress of the pull to stderr
+		cmd.Stdout = os.Stdout
+		teeStderr(cmd, &errb)
+	} else {
+		cmd.Stdout = &outb
+		cmd.Stderr = &errb
+	}
+	if err := runCommand(cmd); err != nil {
+		return fmt.Errorf("an error occurred when executing '%s compose pull'. Command line arguments were:\n  %s\n%s%s", containerManagerCmd, strings.Join(cmd.Args, " "), errb.String(), err)
+	}
+	return nil
+}
+
+// ComposeLogsCommand prepares the command showing the logs of the services of a compose definition
+func ComposeLogsCommand(containerManagerCmd string, composeConfName string, services []string, opts LogOptions) (*exec.Cmd, error) {
+	logs_args := append([]string{"logs"}, opts.args()...)
+	return composeCommand(containerManagerCmd, composeConfName, append(logs_args, services...)...)
+}
+
+// composeAction executes a non-interactive compose command, such as 'stop' or 'down', for a compose definition
+func composeAction(containerManagerCmd string, composeConfName string, args ...string) error {
+	var errb bytes.Buffer
+	cmd, err := composeCommand(containerManagerCmd, composeConfName, args...)
+	if err != nil {
+		return err
+	}
+	forDefinition(cmd, composeConfName)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = &errb
+	log.Printf("Command line arguments are:\n  %s", strings.Join(cmd.Args, " "))
+	if err := runCommand(cmd); err != nil {
+		return fmt.Errorf("an error occurred when executing '%s compose %s'. Command line arguments were:\n  %s\n%s%s", containerManagerCmd, args[0], strings.Join(cmd.Args, " "), errb.String(), err)
+	}
+	return nil
+}
+
+// ComposeRestart restarts the services of a compose definition. An empty list of services means all of them.
+func ComposeRestart(containerManagerCmd string, composeConfName string, services []string) error {
+	log.Printf("Restarting %s", describeCompose(composeConfName, services))
+	return composeAction(containerManagerCmd, composeConfName, append([]string{"restart"}, services...)...)
+}
+
+// ComposeStop stops the services of a compose definition, without removing their containers.
+func ComposeStop(containerManagerCmd string, composeConfName string, services []string) error {
+	log.Printf("Stopping %s", describeCompose(composeConfName, services))
+	return composeAction(containerManagerCmd, composeConfName, append([]string{"stop"}, services...)...)
+}
+
+// ComposeDown stops and removes the containers and networks of a compose definition.
+// If services are provided, only their containers are stopped and removed, with 'compose rm'.
+// If volumes is true, the volumes of the stack (or the anonymous volumes of the services) are removed as well.
+func ComposeDown(containerManagerCmd string, composeConfName string, services []string, volumes bool) error {
+	log.Printf("Stopping and removing %s", describeCompose(composeConfName, services))
+	var down_args []string
+	if len(services) == 0 {
+		down_args = []string{"down"}
+	} else {
+		down_args = []string{"rm", "--stop", "--force"}
+	}
+	if volumes {
+		down_args = append(down_args, "--volumes")
+	}
+	return composeAction(containerManagerCmd, composeConfName, append(down_args, services...)...)
+}
+
+// ComposeExec attaches an additional session to a running service of a compose definition
+func ComposeExec(containerManagerCmd string, composeConfName string, exec_args []string) error {
+	log.Printf("Attaching an additional session to running compose stack '%s'", composeConfName)
+	exec_args, err := adjustComposeTTY(composeConfName, exec_args)
+	if err != nil {
+		return err
+	}
+	cmd, err := composeCommand(containerManagerCmd, composeConfName, append([]string{"exec"}, exec_args...)...)
+	if err != nil {
+		return err
+	}
+	forDefinition(cmd, composeConfName)
+	// Redirect all input and output of the parent to the child process
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	// the stderr of the session is shown while it is written, as for the other commands
+	var errb stderrTail
+	teeStderr(cmd, &errb)
+	log.Printf("Command line arguments are:\n  %s", strings.Join(cmd.Args, " "))
+	err = runCommand(cmd)
+	switch err.(type) {
+	case nil: // program terminates here in best case
+	case *exec.Error:
+		// check if the error was raised at the system level, such as if the container manager is not installed.
+		log.Printf("An error occurred when executing compose service\nCommand line arguments were:\n%s", strings.Join(cmd.Args, " "))
+		return err
+	case *exec.ExitError:
+		// the exit code of the last command executed within the session is returned, as for 'exec' into containers
+		// startainer exits with the same exit code, see exitOnError
+		log.Print("Session terminated.")
+		return err
 	}
+	return nil
 }
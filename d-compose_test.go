@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestComposeExecServiceIndex(t *testing.T) {
+	tests := []struct {
+		exec_args []string
+		want      int
+	}{
+		{[]string{"web", "sh"}, 0},
+		{[]string{"-T", "web", "sh"}, 1},
+		{[]string{"-u", "root", "web", "sh"}, 2},
+		{[]string{"--user=root", "web", "sh"}, 1},
+		{[]string{"-e", "A=1", "--env", "B=2", "-w", "/srv", "--workdir", "/tmp", "--index", "2", "web", "sh"}, 10},
+		{[]string{"-u", "root"}, -1},
+		{nil, -1},
+	}
+	for _, test := range tests {
+		if got := composeExecServiceIndex(test.exec_args); got != test.want {
+			t.Errorf("composeExecServiceIndex(%q) = %d, want %d", test.exec_args, got, test.want)
+		}
+	}
+}
@@ -14,66 +14,192 @@ import (
 	"github.com/yalp/jsonpath"
 )
 
-func ManageContainer(containerManagerCmd string, containerName string, additionalArgs []string) {
+/*
+ManageContainer brings the container of a definition to the running state, depending on its status:
+a missing container is created with 'run', a stopped one is started with 'start', and a session is attached to a running one with 'exec'.
+*/
+func ManageContainer(containerManagerCmd string, containerName string, additionalArgs []string) error {
 	//log.Printf("Retrieving information about container '%s'", containerName)
 	status, err := ContainerStatus(containerManagerCmd, containerName, true)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	return manageContainerStatus(containerManagerCmd, containerName, status, additionalArgs)
+}
+
+// manageContainerStatus brings the container of a definition to the running state, as ManageContainer does, given its current status
+func manageContainerStatus(containerManagerCmd string, containerName string, status string, additionalArgs []string) error {
+	if IsExited(status) {
+		log.Printf("The container '%s' %s", containerName, styleStatus(status))
+		status = STOPPED
+	} else if status == UNHEALTHY {
+		log.Printf("The container '%s' is running, but %s", containerName, styleStatus(status))
+		status = RUNNING
+	}
+	switch status {
+	case MISSING:
+		explain("container '%s' is %s: it will be created with 'run'", containerName, status)
+	case STOPPED:
+		explain("container '%s' is %s: it will be started with 'start'", containerName, status)
+	case RUNNING:
+		explain("container '%s' is %s: a session will be attached with 'exec'", containerName, status)
+	}
+	if execProfile != "" && status != RUNNING {
+		log.Printf("The container '%s' is not running yet: the 'exec' profile '%s' is not used", containerName, execProfile)
 	}
 	switch status {
+	case RESTARTING:
+		return fmt.Errorf("the container '%s' is %s. Wait for it to be running, or use 'rm' to reset it", containerName, styleStatus(status))
 	case MISSING:
 		// the container is missing, need to "run"
-		if viper.IsSet(containerName + ".image") {
-			// check if the image is actually available
-			// if not, pull it.
-			image_name := viper.GetString(containerName + ".image")
-			if image_status, err := ImageStatus(containerManagerCmd, image_name, true); err != nil {
-				log.Fatalf("%v", err)
-			} else {
-				if image_status == MISSING {
-					if err := ImagePull(containerManagerCmd, image_name, true); err != nil {
-						log.Fatalf("%v", err)
-					}
-				}
-			}
+		if err := prepareImage(containerManagerCmd, containerName); err != nil {
+			return err
 		}
 		if viper.IsSet(containerName + ".run") {
-			run_args := append(viper.GetStringSlice(containerName+".run"), additionalArgs...)
+			if err := CheckPorts(containerManagerCmd, containerName); err != nil {
+				return err
+			}
+			explain("the ports published by '%s' are free", containerName)
+			run_args := append(RunConfig(containerName), additionalArgs...)
 
 			// Append the command-line parameters the user provided to the container manager run command, to the ones specified within the config file
-			ContainerRun(containerManagerCmd, containerName, run_args, viper.GetString(containerName+".message"))
-		} else {
-			log.Fatal(red("No configurations for '%s run' are present within the config file", containerManagerCmd))
+			return ContainerRun(containerManagerCmd, containerName, run_args, viper.GetString(containerName+".message"))
 		}
+		return fmt.Errorf(red("no configurations for '%s run' are present within the config file", containerManagerCmd))
 	case STOPPED:
+		// the ports published by the stopped container might have been taken in the meantime
+		if err := CheckPorts(containerManagerCmd, containerName); err != nil {
+			return err
+		}
 		if viper.IsSet(containerName + ".start") {
-			ContainerStart(containerManagerCmd, containerName, viper.GetStringSlice(containerName+".start"), viper.GetString(containerName+".message"))
+			return ContainerStart(containerManagerCmd, containerName, viper.GetStringSlice(containerName+".start"), viper.GetString(containerName+".message"))
+		}
+		log.Printf("The container is stopped, but no configurations for '%s start' are present within the config file. Defaulting to standard command", containerManagerCmd)
+		if IsIn("-d", RunConfig(containerName)) {
+			// The "run" command specifies detached mode (-d), thus, by default, we do not attach stdin and stdout when doing start
+			return ContainerStart(containerManagerCmd, containerName, []string{ContainerName(containerManagerCmd, containerName)}, viper.GetString(containerName+".message"))
+		}
+		return ContainerStart(containerManagerCmd, containerName, []string{"-ai", ContainerName(containerManagerCmd, containerName)}, viper.GetString(containerName+".message"))
+	case RUNNING:
+		exec_args, found, err := execConfig(containerName)
+		if err != nil {
+			return err
+		}
+		if found {
+			return ContainerExec(containerManagerCmd, containerName, exec_args)
+		}
+		log.Printf("The container is already running, but no configurations for '%s exec' are present within the config file. Defaulting to the first available shell", containerManagerCmd)
+		shell, err := probeContainerShell(containerManagerCmd, containerName)
+		if err != nil {
+			return err
+		}
+		return ContainerExec(containerManagerCmd, containerName, []string{"-ti", ContainerName(containerManagerCmd, containerName), shell})
+	}
+	return nil
+}
+
+// prepareImage builds or pulls the image of a container definition before running its container, if the
+// build configuration or the pull policy require it
+func prepareImage(containerManagerCmd string, containerName string) error {
+	if HasBuild(containerName) {
+		// the image is built locally: check if it is missing or outdated
+		if build, reason, err := BuildNeeded(containerManagerCmd, containerName, true); err != nil {
+			return err
+		} else if !build {
+			explain("image will not be built: %s", reason)
 		} else {
-			log.Printf("The container is stopped, but no configurations for '%s start' are present within the config file. Defaulting to standard command", containerManagerCmd)
-			if IsIn("-d", viper.GetStringSlice(containerName+".run")) {
-				// The "run" command specifies detached mode (-d), thus, by default, we do not attach stdin and stdout when doing start
-				ContainerStart(containerManagerCmd, containerName, []string{containerName}, viper.GetString(containerName+".message"))
-			} else {
-				ContainerStart(containerManagerCmd, containerName, []string{"-ai", containerName}, viper.GetString(containerName+".message"))
+			log.Printf("Image for '%s' needs to be built: %s", containerName, reason)
+			explain("image will be built: %s", reason)
+			if err := ImageBuild(containerManagerCmd, containerName); err != nil {
+				return err
 			}
 		}
-	case RUNNING:
-		if viper.IsSet(containerName + ".exec") {
-			ContainerExec(containerManagerCmd, containerName, viper.GetStringSlice(containerName+".exec"))
+	} else if viper.IsSet(containerName + ".image") {
+		// check if the image has to be pulled, depending on the pull policy
+		// and whether the image is actually available
+		image_name := viper.GetString(containerName + ".image")
+		if pull, reason, err := ShouldPull(containerManagerCmd, containerName, image_name, true); err != nil {
+			return err
+		} else if !pull {
+			explain("image '%s' will not be pulled: %s", image_name, reason)
 		} else {
-			log.Printf("The container is already running, but no configurations for '%s exec' are present within the config file. Defaulting to standard command", containerManagerCmd)
-			ContainerExec(containerManagerCmd, containerName, []string{"-ti", containerName, "/bin/bash"})
+			log.Printf("Image '%s' needs to be pulled: %s", image_name, reason)
+			explain("image '%s' will be pulled: %s", image_name, reason)
+			if err := ImagePull(containerManagerCmd, image_name, true); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
+}
+
+// RunConfig returns the 'run' configuration of a container definition, preceded by
+// a '-p' parameter for each of the items of its 'ports' configuration
+func RunConfig(containerName string) []string {
+	var run_args []string
+	for _, port := range ConfigStringList(containerName + ".ports") {
+		run_args = append(run_args, "-p="+port)
+	}
+	return append(run_args, viper.GetStringSlice(containerName+".run")...)
+}
+
+// DEFINITIONLABEL is the label set on the containers run by startainer, whose value is the name of their definition
+const DEFINITIONLABEL string = "startainer.definition"
+
+// containerNames caches the names of the containers of the definitions, as resolved by ContainerName
+var containerNames = make(map[string]string)
+
+// forgetContainerNames removes the given definitions (all of them if none is given) from the cache of the names of the containers,
+// as their containers might have been re-created since the names were resolved
+func forgetContainerNames(definitions ...string) {
+	if len(definitions) == 0 {
+		containerNames = make(map[string]string)
+	}
+	for _, definition := range definitions {
+		delete(containerNames, definition)
+	}
+}
+
+/*
+ContainerName returns the name of the container of a definition.
+The container is searched by its label 'startainer.definition=<definition>', so that containers having
+a custom '--name', or created by compose from an exported definition, are found as well.
+If no container has the label, the name is the one set with '--name' within 'run', or the name of the definition.
+*/
+func ContainerName(containerManagerCmd string, definition string) string {
+	if name, ok := containerNames[definition]; ok {
+		return name
+	}
+	var outb bytes.Buffer
+	cmd := exec.Command(containerManagerCmd, "ps", "-a", "--filter", "label="+DEFINITIONLABEL+"="+definition, "--format", "{{.Names}}")
+	cmd.Stdout = &outb
+	name := ""
+	if err := cmd.Run(); err == nil {
+		if names := strings.Fields(outb.String()); len(names) > 0 {
+			name = names[0]
+			if len(names) > 1 {
+				log.Printf("Warning: multiple containers are labeled as belonging to '%s': %s. Using '%s'", definition, strings.Join(names, ", "), name)
+			}
+		}
+	}
+	if name == "" {
+		name = definition
+		if spec, err := parseRunArgs(RunConfig(definition)); err == nil && spec.Value("--name") != "" {
+			name = spec.Value("--name")
+		}
+	}
+	containerNames[definition] = name
+	return name
 }
 
 func ContainerStatus(containerManagerCmd string, containerName string, verbose bool) (status string, err error) {
+	defer func() { resolveStatus(containerName, status) }()
 	var outb, errb bytes.Buffer
 	if verbose {
 		log.Printf("Retrieving information about container '%s'", containerName)
 	}
 
-	cmd := exec.Command(containerManagerCmd, "container", "inspect", containerName)
+	cmd := exec.Command(containerManagerCmd, "container", "inspect", ContainerName(containerManagerCmd, containerName))
 	// Redirect all input and output of the parent to the child process
 	// this is used to be able to read the stdout and stderr of the container manager command
 	cmd.Stdout = &outb
@@ -83,32 +209,33 @@ func ContainerStatus(containerManagerCmd string, containerName string, verbose b
 	case nil:
 		// the container is present, need to check if it is running or not
 		var inspect_output interface{}
-		var is_running interface{}
+		var state interface{}
 		err = json.Unmarshal(outb.Bytes(), &inspect_output)
 		if err != nil {
 			log.Printf("Impossible to convert output of '%s inspect' to Json", containerManagerCmd)
-			log.Fatal(err)
 			return ERROR, err
 		}
-		is_running, err = jsonpath.Read(inspect_output, "$[0].State.Running")
+		state, err = jsonpath.Read(inspect_output, "$[0].State")
 		if err != nil {
 			log.Printf("Error when reading '%s container inspect' output", containerManagerCmd)
-			log.Printf("Is_running = %v", is_running)
-			log.Fatal(err)
+			log.Printf("State = %v", state)
 			return ERROR, err
-		} else {
-			if is_running.(bool) {
-				// container is running
-				return RUNNING, nil
-			} else {
-				return STOPPED, nil
+		}
+		if verbose && ConfigType(containerName) == CONFTYPECONTAINER {
+			// containers created by older versions of startainer, or by hand, are tracked by their name only
+			labels, _ := jsonpath.Read(inspect_output, "$[0].Config.Labels")
+			if labels_map, _ := labels.(map[string]interface{}); labels_map[DEFINITIONLABEL] == nil {
+				log.Printf("Warning: the container '%s' lacks the label '%s=%s'. It is tracked by its name only: remove it with 'rm' to have it re-created with the label", ContainerName(containerManagerCmd, containerName), DEFINITIONLABEL, containerName)
 			}
 		}
+		if state_map, ok := state.(map[string]interface{}); ok {
+			return containerStateStatus(state_map), nil
+		}
+		return ERROR, fmt.Errorf("unexpected format of 'State' within '%s container inspect' output", containerManagerCmd)
 	case *exec.Error:
 		// check if the error was raised at the system level, such as if container manager is not installed.
 		log.Printf("System-error occurred when executing '%s container inspect'. Command line arguments were:\n  %s", containerManagerCmd, strings.Join(cmd.Args, " "))
 		log.Print(errb.String())
-		log.Fatal(err)
 	case *exec.ExitError:
 		// this is raised id the executed command does not return 0
 		exitError, _ := err.(*exec.ExitError)
@@ -135,16 +262,40 @@ func ContainerStatus(containerManagerCmd string, containerName string, verbose b
 			// check if the error was raised at the system level, such as if container manager is not installed.
 			log.Printf("An error occurred when executing '%s container inspect'. Command line arguments were:\n  %s", containerManagerCmd, strings.Join(cmd.Args, " "))
 			log.Print(errb.String())
-			log.Fatal(exitError)
 		}
 	}
 	return ERROR, err
 }
 
-func ContainerRun(containerManagerCmd string, containerName string, run_args []string, message string) {
-	log.Printf("Starting container '%s'", containerName)
+/*
+containerStateStatus translates the 'State' object of the output of 'container inspect' into a status.
+docker provides the health within 'State.Health.Status', podman within 'State.Healthcheck.Status'.
+*/
+func containerStateStatus(state map[string]interface{}) string {
+	state_status, _ := state["Status"].(string)
+	if restarting, _ := state["Restarting"].(bool); restarting || state_status == "restarting" {
+		return RESTARTING
+	}
+	if running, _ := state["Running"].(bool); running {
+		for _, key := range []string{"Health", "Healthcheck"} {
+			if health, ok := state[key].(map[string]interface{}); ok && health["Status"] == "unhealthy" {
+				return UNHEALTHY
+			}
+		}
+		return RUNNING
+	}
+	// JSON numbers are unmarshaled as float64
+	if exit_code, _ := state["ExitCode"].(float64); exit_code != 0 {
+		return ExitedStatus(int(exit_code))
+	}
+	return STOPPED
+}
 
-	// Replace ~ and . within volume definitions
+/*
+expandRunPaths replaces ~ and . at the beginning of the host paths of volumes and mounts with their local, absolute counterparts.
+It also returns true if the name of the container is set within the arguments.
+*/
+func expandRunPaths(run_args []string) ([]string, bool) {
 	prev_conf := ""
 	containerName_was_set := false
 	for i, curr_conf := range run_args {
@@ -179,30 +330,62 @@ func ContainerRun(containerManagerCmd string, containerName string, run_args []s
 
 		prev_conf = curr_conf
 	}
+	return run_args, containerName_was_set
+}
+
+/*
+startFailureHint explains the usual reasons why the runtime fails to start the container or compose stack of a definition,
+out of the tail of its stderr. An empty string is returned if the failure cannot be classified.
+*/
+func startFailureHint(definition string, stderr string) string {
+	stderr = strings.ToLower(stderr)
+	switch {
+	case strings.Contains(stderr, "is already in use by") || strings.Contains(stderr, "conflict. the container name"):
+		return fmt.Sprintf("The name of the container of '%s' is already used by another container: remove that container, or change '--name' within the 'run' configuration", definition)
+	case strings.Contains(stderr, "port is already allocated") || strings.Contains(stderr, "address already in use"):
+		return fmt.Sprintf("A port published by '%s' is already in use by another container or process: stop it, or change the published ports", definition)
+	}
+	return ""
+}
+
+func ContainerRun(containerManagerCmd string, containerName string, run_args []string, message string) error {
+	log.Printf("Starting container '%s'", containerName)
+
+	// Replace ~ and . within volume definitions
+	run_args, containerName_was_set := expandRunPaths(run_args)
+	run_args, err := adjustTTY(containerName, "run", run_args)
+	if err != nil {
+		return err
+	}
+	var onInterrupt func()
+	if spec, err := parseRunArgs(run_args); err == nil && !spec.Has("--detach") {
+		if onInterrupt, err = interruptAction(containerManagerCmd, containerName, nil); err != nil {
+			return err
+		}
+	}
 
 	if containerName_was_set {
-		// prepend the "run" parameter
-		run_args = append([]string{"run"}, run_args...)
+		// prepend the "run" parameter, and the label used to find the container of the definition
+		run_args = append([]string{"run", "--label=" + DEFINITIONLABEL + "=" + containerName}, run_args...)
 	} else {
-		// prepend the "run" parameter, and force the container name
-		run_args = append([]string{"run", "--name=" + containerName}, run_args...)
+		// prepend the "run" parameter and the label, and force the container name
+		run_args = append([]string{"run", "--label=" + DEFINITIONLABEL + "=" + containerName, "--name=" + containerName}, run_args...)
 	}
 
-	cmd := exec.Command(containerManagerCmd, run_args...)
+	cmd := forDefinition(exec.Command(containerManagerCmd, run_args...), containerName)
 	// Redirect all input and output of the parent to the child process
 	cmd.Stdout = os.Stdout
 	cmd.Stdin = os.Stdin
 	// this is used to be able to read the stderr of the container manager command
-	var errb bytes.Buffer
-	// cmd.Stderr = os.Stderr
-	cmd.Stderr = &errb
+	var errb stderrTail
+	teeStderr(cmd, &errb)
 
 	log.Printf("Container startup arguments are:\n  %s", strings.Join(cmd.Args, " "))
 	if message != "" {
 		log.Print(blue(message))
 	}
 	// execute the command and wait for its completion
-	err := cmd.Run()
+	err = runInterruptible(cmd, onInterrupt)
 	// check for errors, depending by their type
 	switch err.(type) {
 	case nil:
@@ -210,8 +393,7 @@ func ContainerRun(containerManagerCmd string, containerName string, run_args []s
 	case *exec.Error:
 		// check if the error was raised at the system level, such as if container manager is not installed.
 		log.Printf("An error occurred when running container. Command line arguments were:\n  %s", strings.Join(cmd.Args, " "))
-		log.Print(errb.String())
-		log.Fatal(err)
+		return err
 	case *exec.ExitError:
 		// this is raised id the executed command does not return 0
 		exitError, _ := err.(*exec.ExitError)
@@ -222,63 +404,83 @@ func ContainerRun(containerManagerCmd string, containerName string, run_args []s
 			// the container is already running, try using the exec command
 			ContainerExec(containerManagerCmd, containerName)
 		} else { // */
-		log.Printf("Unexpected error by executing '%s run'. Exit code is %d", containerManagerCmd, exitError.ExitCode())
-		log.Print(errb.String())
-		log.Fatal(exitError)
+		// when interrupted, the exit code is expected: the summary is printed out by exitOnError
+		if hint := startFailureHint(containerName, errb.String()); hint != "" {
+			log.Print(red(hint))
+		} else if interruption() == nil {
+			log.Printf("Unexpected error by executing '%s run'. Exit code is %d", containerManagerCmd, exitError.ExitCode())
+		}
+		return exitError
 		//}
 	}
+	return nil
 }
 
-func ContainerStart(containerManagerCmd string, containerName string, start_args []string, message string) {
+func ContainerStart(containerManagerCmd string, containerName string, start_args []string, message string) error {
 	log.Printf("Restarting stopped container '%s'", containerName)
+	start_args, err := adjustTTY(containerName, "start", start_args)
+	if err != nil {
+		return err
+	}
+	var onInterrupt func()
+	if startAttached(start_args) {
+		if onInterrupt, err = interruptAction(containerManagerCmd, containerName, nil); err != nil {
+			return err
+		}
+	}
 	start_args = append([]string{"start"}, start_args...)
-	cmd := exec.Command(containerManagerCmd, start_args...)
+	cmd := forDefinition(exec.Command(containerManagerCmd, start_args...), containerName)
 	// Redirect all input and output of the parent to the child process
 	cmd.Stdout = os.Stdout
 	cmd.Stdin = os.Stdin
 	// this is used to be able to read the stderr of the container manager command
-	var errb bytes.Buffer
-	cmd.Stderr = &errb
+	var errb stderrTail
+	teeStderr(cmd, &errb)
 	if message != "" {
 		log.Print(blue(message))
 	}
-	err := cmd.Run()
+	err = runInterruptible(cmd, onInterrupt)
 	switch err.(type) {
 	case nil: // program terminates here in best case
 	case *exec.Error:
 		// check if the error was raised at the system level, such as if container manager is not installed.
 		log.Printf("An error occurred when starting container\nCommand line arguments were:\n  %s", strings.Join(cmd.Args, " "))
-		log.Print(errb.String())
-		log.Fatal(err)
+		return err
 	case *exec.ExitError:
 		// this is raised id the executed command does not return 0
 		//exitError, _ := err.(*exec.ExitError)
 		log.Printf("An error occurred when starting container\nCommand line arguments were:\n  %s", strings.Join(cmd.Args, " "))
-		log.Print(errb.String())
-		log.Fatal(err)
+		if hint := startFailureHint(containerName, errb.String()); hint != "" {
+			log.Print(red(hint))
+		}
+		return err
 	}
+	return nil
 }
 
-func ContainerExec(containerManagerCmd string, containerName string, exec_args []string) {
+func ContainerExec(containerManagerCmd string, containerName string, exec_args []string) error {
 	log.Printf("Attaching an additional session to running container '%s'", containerName)
+	exec_args, err := adjustTTY(containerName, "exec", exec_args)
+	if err != nil {
+		return err
+	}
 	// add "exec" at the beginning of the arguments
 	exec_args = append([]string{"exec"}, exec_args...)
-	cmd := exec.Command(containerManagerCmd, exec_args...)
+	cmd := forDefinition(exec.Command(containerManagerCmd, exec_args...), containerName)
 	// Redirect all input and output of the parent to the child process
 	cmd.Stdout = os.Stdout
 	cmd.Stdin = os.Stdin
-	// this is used to be able to read the stderr of the container manager command
-	var errb bytes.Buffer
-	cmd.Stderr = &errb
+	// the stderr of the session is shown while it is written, as for the other commands
+	var errb stderrTail
+	teeStderr(cmd, &errb)
 	log.Printf("Command line arguments are:\n  %s", strings.Join(cmd.Args, " "))
-	err := cmd.Run()
+	err = runCommand(cmd)
 	switch err.(type) {
 	case nil: // program terminates here in best case
 	case *exec.Error:
 		// check if the error was raised at the system level, such as if the container manager is not installed.
 		log.Printf("An error occurred when executing container\nCommand line arguments were:\n%s", strings.Join(cmd.Args, " "))
-		log.Print(errb.String())
-		log.Fatal(err)
+		return err
 	case *exec.ExitError:
 		// this is raised id the executed command does not return 0
 		exitError, _ := err.(*exec.ExitError)
@@ -293,124 +495,70 @@ func ContainerExec(containerManagerCmd string, containerName string, exec_args [
 			// and that command raised an error, then the user exits the shell (with ctrl+D)
 			// the parent program intercepts the exit code of the program within the container
 			// we cannot distinguish on them here....
-			log.Printf("Session terminated. Exit code is %d. %s", exitError.ExitCode(), errb.String())
+			log.Print("Session terminated.")
 		}
+		// startainer exits with the same exit code, see exitOnError
+		return exitError
 	}
+	return nil
 }
 
-func ListSingleContainer(containerManagerCmd string, containerName string) {
-	var configsList []string
-	status, err := ContainerStatus(containerManagerCmd, containerName, false)
-	if err != nil {
-		log.Fatal(err)
-	}
-	log.Printf("The container '%s' is %s", bold(containerName), styleStatus(status))
-	configsList = viper.GetStringSlice(containerName + ".run")
-	log.Printf("RUN configurations for the container:\n    %s run\n    %s\n", containerManagerCmd, strings.Join(configsList, "\n    "))
-
-	if viper.IsSet(containerName + ".exec") {
-		configsList = viper.GetStringSlice(containerName + ".exec")
-		log.Printf("EXEC configurations for the container:\n    %s exec\n    %s\n", containerManagerCmd, strings.Join(configsList, "\n    "))
-	}
-	if viper.IsSet(containerName + ".start") {
-		configsList = viper.GetStringSlice(containerName + ".start")
-		log.Printf("EXEC configurations for the container:\n    %s start\n    %s\n", containerManagerCmd, strings.Join(configsList, "\n    "))
+// ContainerStop stops the running container of a definition
+func ContainerStop(containerManagerCmd string, containerName string) error {
+	var errb bytes.Buffer
+	definition := containerName
+	containerName = ContainerName(containerManagerCmd, containerName)
+	log.Printf("Stopping container '%s'", containerName)
+	cmd := forDefinition(exec.Command(containerManagerCmd, "stop", containerName), definition)
+	cmd.Stderr = &errb
+	if err := runCommand(cmd); err != nil {
+		return fmt.Errorf("an error occurred when stopping container. Command line arguments were:\n  %s\n%s%s", strings.Join(cmd.Args, " "), errb.String(), err)
 	}
+	return nil
 }
 
-func ImagePull(containerManagerCmd string, image_name string, verbose bool) (err error) {
-	var outb, errb bytes.Buffer
-	log.Printf("Pulling image '%s'.\n  If this fails, you might have to manually perform '%s login' or '%s login <registry>'", image_name, containerManagerCmd, containerManagerCmd)
-	cmd := exec.Command(containerManagerCmd, "image", "pull", image_name)
-	if verbose {
-		// redirect child's process output to StdOut so that user can see it.
-		cmd.Stdout = os.Stdout
-	} else {
-		// keep stdout internal
-		cmd.Stdout = &outb
+// ContainerRemove removes the stopped container of a definition.
+// If volumes is true, the anonymous volumes associated with the container are removed as well.
+func ContainerRemove(containerManagerCmd string, containerName string, volumes bool) error {
+	var errb bytes.Buffer
+	definition := containerName
+	containerName = ContainerName(containerManagerCmd, containerName)
+	log.Printf("Removing container '%s'", containerName)
+	rm_args := []string{"rm"}
+	if volumes {
+		rm_args = append(rm_args, "--volumes")
 	}
-	// this is used to be able to read stderr of the container manager command
+	cmd := forDefinition(exec.Command(containerManagerCmd, append(rm_args, containerName)...), definition)
 	cmd.Stderr = &errb
-	err = cmd.Run()
-	switch err.(type) {
-	case nil:
-		log.Print("Image downloaded")
-		return nil
-	case *exec.Error:
-		// check if the error was raised at the system level, such as if container manager is not installed.
-		log.Printf("An error occurred when executing '%s pull'. Command line arguments were:\n  %s", containerManagerCmd, strings.Join(cmd.Args, " "))
-		log.Print(errb.String())
-		log.Fatal(err)
-	case *exec.ExitError:
-		// this is raised id the executed command does not return 0
-		exitError, _ := err.(*exec.ExitError)
-		switch {
-		case exitError.ExitCode() == 1 && strings.Contains(errb.String(), "not found"):
-			log.Printf("Image '%s' not found. Docker wrote:\n  %s", image_name, errb.String())
-			log.Fatal(exitError)
-		default:
-			// check if the error was raised at the system level, such as if container manager is not installed.
-			log.Printf("An error occurred when executing '%s pull'. Command line arguments were:\n  %s", containerManagerCmd, strings.Join(cmd.Args, " "))
-			log.Print(errb.String())
-			log.Fatal(exitError)
-		}
+	if err := runCommand(cmd); err != nil {
+		return fmt.Errorf("an error occurred when removing container. Command line arguments were:\n  %s\n%s%s", strings.Join(cmd.Args, " "), errb.String(), err)
 	}
 	return nil
 }
 
-func ImageStatus(containerManagerCmd string, image_name string, verbose bool) (status string, err error) {
-	var outb, errb bytes.Buffer
-	if verbose {
-		log.Printf("Retrieving information about image '%s'", image_name)
+// ContainerLogsCommand prepares the command showing the logs of the container of a definition
+func ContainerLogsCommand(containerManagerCmd string, containerName string, opts LogOptions) *exec.Cmd {
+	logs_args := append([]string{"logs"}, opts.args()...)
+	return exec.Command(containerManagerCmd, append(logs_args, ContainerName(containerManagerCmd, containerName))...)
+}
+
+// containerConfigSections describes the 'run', 'exec' and 'start' configurations of a container definition, one section per command
+func containerConfigSections(containerManagerCmd string, containerName string) []string {
+	sections := []string{fmt.Sprintf("RUN configurations for the container:\n    %s run\n    %s\n", containerManagerCmd, strings.Join(RunConfig(containerName), "\n    "))}
+	sections = append(sections, execConfigSections(containerName, "container", containerManagerCmd+" exec")...)
+	if viper.IsSet(containerName + ".start") {
+		sections = append(sections, fmt.Sprintf("START configurations for the container:\n    %s start\n    %s\n", containerManagerCmd, strings.Join(viper.GetStringSlice(containerName+".start"), "\n    ")))
 	}
+	return sections
+}
 
-	cmd := exec.Command(containerManagerCmd, "image", "inspect", image_name)
-	// Redirect all input and output of the parent to the child process
-	// this is used to be able to read the stdout and stderr of the container manager command
-	cmd.Stdout = &outb
-	cmd.Stderr = &errb
-	err = cmd.Run()
-	switch err.(type) {
-	case nil:
-		// the container is present, need to check if it is running or not
-		var inspect_output interface{}
-		err = json.Unmarshal(outb.Bytes(), &inspect_output)
-		if err != nil {
-			log.Printf("Impossible to convert output of '%s inspect' to Json", containerManagerCmd)
-			log.Fatal(err)
-			return ERROR, err
-		}
-		_, err = jsonpath.Read(inspect_output, "$[0].Created")
-		if err != nil {
-			log.Printf("Error when reading '%s image inspect' output", containerManagerCmd)
-			log.Fatal(err)
-			return ERROR, err
-		}
-		if verbose {
-			log.Print("Image already existing")
-		}
-		return IMAGE_EXISTING, nil
-	case *exec.Error:
-		// check if the error was raised at the system level, such as if container manager is not installed.
-		log.Printf("A system error occurred when executing '%s image inspect'. Command line arguments were:\n  %s", containerManagerCmd, strings.Join(cmd.Args, " "))
-		log.Print(errb.String())
+func ListSingleContainer(containerManagerCmd string, containerName string) {
+	status, err := ContainerStatus(containerManagerCmd, containerName, false)
+	if err != nil {
 		log.Fatal(err)
-	case *exec.ExitError:
-		// this is raised id the executed command does not return 0
-		exitError, _ := err.(*exec.ExitError)
-		switch {
-		case // docker
-			(exitError.ExitCode() == 1 && (strings.Contains(errb.String(), "Error: No such image") || strings.Contains(errb.String(), "Error: No such object"))) ||
-				// podman
-				(exitError.ExitCode() == 125 && (strings.Contains(errb.String(), "image not known") || strings.Contains(errb.String(), "failed to find image"))):
-			// the image is missing
-			return MISSING, nil
-		default:
-			// check if the error was raised at the system level, such as if container manager is not installed.
-			log.Printf("An error occurred when executing '%s inspect'. Command line arguments were:\n  %s", containerManagerCmd, strings.Join(cmd.Args, " "))
-			log.Print(errb.String())
-			log.Fatal(exitError)
-		}
 	}
-	return ERROR, err
+	log.Printf("The container '%s' is %s", bold(containerName), styleStatus(status))
+	for _, section := range containerConfigSections(containerManagerCmd, containerName) {
+		log.Print(section)
+	}
 }
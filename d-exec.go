@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// execProfile is set by the '-as' command-line flag: the name of the profile of the 'exec' configuration
+// used to attach to a running container or compose stack
+var execProfile string
+
+// defaultExecProfiles are the profiles used, in this order, when the 'exec' configuration is a map and no profile is requested
+var defaultExecProfiles = []string{"default", "shell"}
+
+// probedShells are the shells searched within a running container, in order of preference, when no 'exec' configuration is present
+var probedShells = []string{"bash", "zsh", "sh"}
+
+/*
+extractExecProfile removes '--as <profile>' (or '-as', '--as=<profile>') from the beginning of the additional parameters
+following the definition, e.g. 'startainer alpine --as root', and sets the requested profile.
+*/
+func extractExecProfile(args []string) []string {
+	switch {
+	case len(args) >= 2 && (args[0] == "--as" || args[0] == "-as"):
+		execProfile = args[1]
+		return args[2:]
+	case len(args) >= 1 && (strings.HasPrefix(args[0], "--as=") || strings.HasPrefix(args[0], "-as=")):
+		execProfile = args[0][strings.Index(args[0], "=")+1:]
+		return args[1:]
+	}
+	return args
+}
+
+/*
+execConfig returns the parameters for 'exec' of a definition. The 'exec' configuration is either a list of parameters,
+or a map of named profiles, e.g. 'exec: {shell: [...], root: [...]}', selected with '-as'.
+Without '-as', the profile 'default' or 'shell' is used. The second return value is false if no configuration applies.
+*/
+func execConfig(definition string) ([]string, bool, error) {
+	key := definition + ".exec"
+	profiles, is_map := viper.Get(key).(map[string]interface{})
+	if execProfile != "" {
+		// viper lowercases the keys of maps
+		profile := strings.ToLower(execProfile)
+		if !is_map || profiles[profile] == nil {
+			return nil, false, fmt.Errorf("no 'exec' profile named '%s' is configured for '%s'. Available profiles: %s", execProfile, definition, strings.Join(execProfileNames(definition), ", "))
+		}
+		return viper.GetStringSlice(key + "." + profile), true, nil
+	}
+	if !viper.IsSet(key) {
+		return nil, false, nil
+	}
+	if !is_map {
+		return viper.GetStringSlice(key), true, nil
+	}
+	for _, profile := range defaultExecProfiles {
+		if profiles[profile] != nil {
+			return viper.GetStringSlice(key + "." + profile), true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// execProfileNames returns the sorted names of the 'exec' profiles of a definition
+func execProfileNames(definition string) []string {
+	var names []string
+	profiles, _ := viper.Get(definition + ".exec").(map[string]interface{})
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		names = []string{"none"}
+	}
+	return names
+}
+
+/*
+execConfigSections describes the 'exec' configuration of a definition, one section per profile if it is a map of profiles.
+'subject' is what the configuration applies to, e.g. 'container', and 'command' the command it is provided to, e.g. 'docker exec'.
+*/
+func execConfigSections(definition string, subject string, command string) []string {
+	key := definition + ".exec"
+	if _, is_map := viper.Get(key).(map[string]interface{}); is_map {
+		var sections []string
+		for _, profile := range execProfileNames(definition) {
+			sections = append(sections, fmt.Sprintf("EXEC configurations for the %s (profile '%s'):\n    %s\n    %s\n", subject, profile, command, strings.Join(viper.GetStringSlice(key+"."+profile), "\n    ")))
+		}
+		return sections
+	}
+	if viper.IsSet(key) {
+		return []string{fmt.Sprintf("EXEC configurations for the %s:\n    %s\n    %s\n", subject, command, strings.Join(viper.GetStringSlice(key), "\n    "))}
+	}
+	return nil
+}
+
+/*
+probeShell returns the first of the probedShells available within a running container.
+Each shell is probed by executing '<shell> -c exit' with the command prepared by 'prepare'.
+*/
+func probeShell(prepare func(shell string) (*exec.Cmd, error)) (string, error) {
+	for _, shell := range probedShells {
+		cmd, err := prepare(shell)
+		if err != nil {
+			return "", err
+		}
+		// probing only reads the state of the container: it is performed in dry-run mode as well
+		if err := cmd.Run(); err == nil {
+			explain("shell '%s' is available", shell)
+			return shell, nil
+		}
+	}
+	return "", fmt.Errorf("none of the shells %s is available within the container. Add an 'exec' configuration to the definition", strings.Join(probedShells, ", "))
+}
+
+// probeContainerShell returns the first of the probedShells available within the running container of a definition
+func probeContainerShell(containerManagerCmd string, containerName string) (string, error) {
+	log.Printf("Searching for a shell within container '%s'", ContainerName(containerManagerCmd, containerName))
+	return probeShell(func(shell string) (*exec.Cmd, error) {
+		return exec.Command(containerManagerCmd, "exec", ContainerName(containerManagerCmd, containerName), shell, "-c", "exit"), nil
+	})
+}
+
+// probeComposeShell returns the first of the probedShells available within the running container of a service of a compose definition
+func probeComposeShell(containerManagerCmd string, composeConfName string, service string) (string, error) {
+	log.Printf("Searching for a shell within service '%s' of compose stack '%s'", service, composeConfName)
+	return probeShell(func(shell string) (*exec.Cmd, error) {
+		return composeCommand(containerManagerCmd, composeConfName, "exec", "-T", service, shell, "-c", "exit")
+	})
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/yalp/jsonpath"
+)
+
+const (
+	// Pull policies for the images of container definitions
+	PULLALWAYS  string = "always"
+	PULLMISSING string = "missing"
+	PULLNEVER   string = "never"
+	PULLDAILY   string = "daily"
+	PULLWEEKLY  string = "weekly"
+	// name of the file, within the state dir, tracking the pulled images
+	IMAGESSTATEFILE string = "images.json"
+)
+
+// imageState is what startainer remembers locally about an image it pulled
+type imageState struct {
+	LastPull time.Time `json:"last_pull"`
+	ID       string    `json:"id"`
+}
+
+// imagesStateLock serializes the accesses to the images state file, as pulls might be executed concurrently
+var imagesStateLock sync.Mutex
+
+// PullPolicy returns the pull policy for the image of a definition.
+// The policy set within the definition has precedence over the one within 'settings.pull'.
+// If none is set, the policy defaults to 'missing'.
+func PullPolicy(definition string) (string, error) {
+	policy := PULLMISSING
+	if viper.IsSet(definition + ".pull") {
+		policy = viper.GetString(definition + ".pull")
+	} else if viper.IsSet("settings.pull") {
+		policy = viper.GetString("settings.pull")
+	}
+	policy = strings.ToLower(strings.TrimSpace(policy))
+	if !IsIn(policy, []string{PULLALWAYS, PULLMISSING, PULLNEVER, PULLDAILY, PULLWEEKLY}) {
+		return "", fmt.Errorf("invalid pull policy '%s' for '%s'. Valid values are: always, missing, never, daily, weekly", policy, definition)
+	}
+	return policy, nil
+}
+
+/*
+ShouldPull decides whether the image of a definition has to be pulled, based on the pull policy of the definition.
+Besides the decision, it returns a short textual reason for it.
+An error is returned if the image is missing and the policy forbids pulling it.
+*/
+func ShouldPull(containerManagerCmd string, definition string, image_name string, verbose bool) (pull bool, reason string, err error) {
+	policy, err := PullPolicy(definition)
+	if err != nil {
+		return false, "", err
+	}
+	image_status, err := ImageStatus(containerManagerCmd, image_name, verbose)
+	if err != nil {
+		return false, "", err
+	}
+	switch policy {
+	case PULLALWAYS:
+		return true, "pull policy is 'always'", nil
+	case PULLNEVER:
+		if image_status == MISSING {
+			return false, "", fmt.Errorf("image '%s' is missing, but the pull policy of '%s' is 'never'", image_name, definition)
+		}
+		return false, "pull policy is 'never'", nil
+	case PULLDAILY, PULLWEEKLY:
+		if image_status == MISSING {
+			return true, "image is missing", nil
+		}
+		interval := 24 * time.Hour
+		if policy == PULLWEEKLY {
+			interval = 7 * 24 * time.Hour
+		}
+		last_pull := LastImagePull(image_name)
+		if last_pull.IsZero() {
+			return true, fmt.Sprintf("pull policy is '%s' and no previous pull was recorded", policy), nil
+		}
+		if time.Since(last_pull) > interval {
+			return true, fmt.Sprintf("pull policy is '%s' and last pull was on %s", policy, last_pull.Format("2006-01-02 15:04")), nil
+		}
+		return false, fmt.Sprintf("pull policy is '%s' and last pull was on %s", policy, last_pull.Format("2006-01-02 15:04")), nil
+	default:
+		if image_status == MISSING {
+			return true, "image is missing", nil
+		}
+		return false, "image already existing", nil
+	}
+}
+
+// ImageID returns the ID of a local image, or an empty string if the image is not available locally
+func ImageID(containerManagerCmd string, image_name string) (string, error) {
+	var outb, errb bytes.Buffer
+	cmd := exec.Command(containerManagerCmd, "image", "inspect", "--format", "{{.Id}}", image_name)
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+	err := cmd.Run()
+	switch err.(type) {
+	case nil:
+		return strings.TrimSpace(outb.String()), nil
+	case *exec.ExitError:
+		// the image is not available locally, the error messages are checked by ImageStatus
+		return "", nil
+	default:
+		return "", fmt.Errorf("error when executing '%s'. %s %s", strings.Join(cmd.Args, " "), err, errb.String())
+	}
+}
+
+// LastImagePull returns the time startainer last pulled the image, or a zero time if this is not known
+func LastImagePull(image_name string) time.Time {
+	imagesStateLock.Lock()
+	defer imagesStateLock.Unlock()
+	images := make(map[string]imageState)
+	if err := readStateFile(IMAGESSTATEFILE, &images); err != nil {
+		log.Printf("Warning: %s", err)
+	}
+	return images[image_name].LastPull
+}
+
+// recordImagePull saves within the state dir the time of the pull of an image and its resulting ID
+func recordImagePull(image_name string, id string) error {
+	imagesStateLock.Lock()
+	defer imagesStateLock.Unlock()
+	images := make(map[string]imageState)
+	if err := readStateFile(IMAGESSTATEFILE, &images); err != nil {
+		return err
+	}
+	images[image_name] = imageState{LastPull: time.Now(), ID: id}
+	return writeStateFile(IMAGESSTATEFILE, images)
+}
+
+// ImagePull pulls an image and records the time of the pull within the state dir.
+// If verbose is true, the output of the pull is shown to the user.
+func ImagePull(containerManagerCmd string, image_name string, verbose bool) (err error) {
+	var outb bytes.Buffer
+	var errb stderrTail
+	log.Printf("Pulling image '%s'.\n  If this fails, you might have to manually perform '%s login' or '%s login <registry>'", image_name, containerManagerCmd, containerManagerCmd)
+	cmd := exec.Command(containerManagerCmd, "image", "pull", image_name)
+	// stderr is captured to be able to classify errors
+	if verbose {
+		// redirect child's process output to StdOut so that user can see it, including progress and warnings written to stderr
+		cmd.Stdout = os.Stdout
+		teeStderr(cmd, &errb)
+	} else {
+		// keep stdout internal
+		cmd.Stdout = &outb
+		cmd.Stderr = &errb
+	}
+	err = runCommand(cmd)
+	switch err.(type) {
+	case nil:
+		if dryRun {
+			return nil
+		}
+		if verbose {
+			log.Print("Image downloaded")
+		}
+		id, _ := ImageID(containerManagerCmd, image_name)
+		if err := recordImagePull(image_name, id); err != nil {
+			log.Printf("Warning: impossible to record the pull of image '%s'. %s", image_name, err)
+		}
+		return nil
+	case *exec.Error:
+		// check if the error was raised at the system level, such as if container manager is not installed.
+		return fmt.Errorf("an error occurred when executing '%s pull'. Command line arguments were:\n  %s\n%s%s", containerManagerCmd, strings.Join(cmd.Args, " "), errb.String(), err)
+	case *exec.ExitError:
+		// this is raised id the executed command does not return 0
+		exitError, _ := err.(*exec.ExitError)
+		switch {
+		case exitError.ExitCode() == 1 && strings.Contains(errb.String(), "not found"):
+			return fmt.Errorf("image '%s' not found. %s wrote:\n  %s", image_name, containerManagerCmd, errb.String())
+		default:
+			return fmt.Errorf("an error occurred when executing '%s pull'. Command line arguments were:\n  %s\n%s%s", containerManagerCmd, strings.Join(cmd.Args, " "), errb.String(), exitError)
+		}
+	}
+	return err
+}
+
+func ImageStatus(containerManagerCmd string, image_name string, verbose bool) (status string, err error) {
+	var outb, errb bytes.Buffer
+	if verbose {
+		log.Printf("Retrieving information about image '%s'", image_name)
+	}
+
+	cmd := exec.Command(containerManagerCmd, "image", "inspect", image_name)
+	// Redirect all input and output of the parent to the child process
+	// this is used to be able to read the stdout and stderr of the container manager command
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+	err = cmd.Run()
+	switch err.(type) {
+	case nil:
+		// the container is present, need to check if it is running or not
+		var inspect_output interface{}
+		err = json.Unmarshal(outb.Bytes(), &inspect_output)
+		if err != nil {
+			log.Printf("Impossible to convert output of '%s inspect' to Json", containerManagerCmd)
+			return ERROR, err
+		}
+		_, err = jsonpath.Read(inspect_output, "$[0].Created")
+		if err != nil {
+			log.Printf("Error when reading '%s image inspect' output", containerManagerCmd)
+			return ERROR, err
+		}
+		if verbose {
+			log.Print("Image already existing")
+		}
+		return IMAGE_EXISTING, nil
+	case *exec.Error:
+		// check if the error was raised at the system level, such as if container manager is not installed.
+		log.Printf("A system error occurred when executing '%s image inspect'. Command line arguments were:\n  %s", containerManagerCmd, strings.Join(cmd.Args, " "))
+		log.Print(errb.String())
+	case *exec.ExitError:
+		// this is raised id the executed command does not return 0
+		exitError, _ := err.(*exec.ExitError)
+		switch {
+		case // docker
+			(exitError.ExitCode() == 1 && (strings.Contains(errb.String(), "Error: No such image") || strings.Contains(errb.String(), "Error: No such object"))) ||
+				// podman
+				(exitError.ExitCode() == 125 && (strings.Contains(errb.String(), "image not known") || strings.Contains(errb.String(), "failed to find image"))):
+			// the image is missing
+			return MISSING, nil
+		default:
+			// check if the error was raised at the system level, such as if container manager is not installed.
+			log.Printf("An error occurred when executing '%s inspect'. Command line arguments were:\n  %s", containerManagerCmd, strings.Join(cmd.Args, " "))
+			log.Print(errb.String())
+		}
+	}
+	return ERROR, err
+}
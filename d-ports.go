@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// publishedPort is a port of the host published to a container, e.g. '-p=127.0.0.1:8000:80/tcp'
+type publishedPort struct {
+	HostIP        string
+	HostPort      int
+	ContainerPort string
+	Proto         string // tcp or udp
+}
+
+func (p publishedPort) String() string {
+	return fmt.Sprintf("%d/%s", p.HostPort, p.Proto)
+}
+
+/*
+parsePublish analyzes the value of '--publish': '[ip:]host-port:container-port[/proto]'.
+The second returned value is false if no fixed port of the host is published, e.g. for '-p=80' or for port ranges.
+*/
+func parsePublish(value string) (publishedPort, bool) {
+	port := publishedPort{Proto: "tcp"}
+	if pos := strings.LastIndex(value, "/"); pos >= 0 {
+		value, port.Proto = value[:pos], strings.ToLower(value[pos+1:])
+	}
+	pos := strings.LastIndex(value, ":")
+	if pos < 0 {
+		// only the port of the container: the runtime chooses a free port of the host
+		return port, false
+	}
+	host, container := value[:pos], value[pos+1:]
+	port.ContainerPort = container
+	if pos := strings.LastIndex(host, ":"); pos >= 0 {
+		port.HostIP = strings.Trim(host[:pos], "[]")
+		host = host[pos+1:]
+	}
+	host_port, err := strconv.Atoi(host)
+	if err != nil {
+		// empty host port or port range
+		return port, false
+	}
+	port.HostPort = host_port
+	return port, true
+}
+
+// conflicts returns true if the two published ports cannot be used at the same time
+func (p publishedPort) conflicts(other publishedPort) bool {
+	if p.HostPort != other.HostPort || p.Proto != other.Proto {
+		return false
+	}
+	all_interfaces := []string{"", "0.0.0.0", "::"}
+	return p.HostIP == other.HostIP || IsIn(p.HostIP, all_interfaces) || IsIn(other.HostIP, all_interfaces)
+}
+
+// DefinitionPorts returns the ports of the host published by a container definition
+func DefinitionPorts(containerName string) ([]publishedPort, error) {
+	spec, err := parseRunArgs(RunConfig(containerName))
+	if err != nil {
+		return nil, err
+	}
+	var ports []publishedPort
+	for _, value := range spec.Values("--publish") {
+		if port, ok := parsePublish(value); ok {
+			ports = append(ports, port)
+		}
+	}
+	return ports, nil
+}
+
+// containerPortsRegex matches the ports listed by 'ps', e.g. '0.0.0.0:8000->8000/tcp' or '[::]:8000->8000/tcp'
+var containerPortsRegex = regexp.MustCompile(`(?:\[?([0-9a-fA-F.:]*)\]?:)?(\d+)(?:-(\d+))?->[\d-]+/(tcp|udp|sctp)`)
+
+// containerPorts are the ports of the host published by a running container
+type containerPorts struct {
+	Definition string // the definition of the container, as set within its DEFINITIONLABEL, if it was run by startainer
+	Ports      []publishedPort
+}
+
+// runningContainerPorts returns the ports of the host published by the running containers, by container name
+func runningContainerPorts(containerManagerCmd string) (map[string]containerPorts, error) {
+	var outb, errb bytes.Buffer
+	cmd := exec.Command(containerManagerCmd, "ps", "--format", "{{.Names}}\t{{.Label \""+DEFINITIONLABEL+"\"}}\t{{.Ports}}")
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("an error occurred when listing the running containers. Command line arguments were:\n  %s\n%s%s", strings.Join(cmd.Args, " "), errb.String(), err)
+	}
+	containers := make(map[string]containerPorts)
+	for _, line := range strings.Split(outb.String(), "\n") {
+		parts := strings.SplitN(line, "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		// containers without the label print out '<no value>'
+		container := containerPorts{Definition: strings.TrimSpace(strings.Replace(parts[1], "<no value>", "", 1))}
+		for _, match := range containerPortsRegex.FindAllStringSubmatch(parts[2], -1) {
+			first, _ := strconv.Atoi(match[2])
+			last := first
+			if match[3] != "" {
+				last, _ = strconv.Atoi(match[3])
+			}
+			for port := first; port <= last; port++ {
+				container.Ports = append(container.Ports, publishedPort{HostIP: match[1], HostPort: port, Proto: match[4]})
+			}
+		}
+		containers[parts[0]] = container
+	}
+	return containers, nil
+}
+
+// portInUse tries to listen on the port of the host, and returns true if it is used by another process
+func portInUse(port publishedPort) bool {
+	address := net.JoinHostPort(port.HostIP, strconv.Itoa(port.HostPort))
+	var err error
+	if port.Proto == "udp" {
+		var conn net.PacketConn
+		if conn, err = net.ListenPacket("udp", address); err == nil {
+			conn.Close()
+		}
+	} else {
+		var listener net.Listener
+		if listener, err = net.Listen("tcp", address); err == nil {
+			listener.Close()
+		}
+	}
+	// other errors, such as missing permissions for privileged ports, are left to the runtime.
+	// On windows, the error is WSAEADDRINUSE, which is not syscall.EADDRINUSE
+	return err != nil && (errors.Is(err, syscall.EADDRINUSE) || strings.Contains(err.Error(), "Only one usage of each socket address"))
+}
+
+/*
+listeningProcess returns the name and pid of the process listening on the port, on linux only.
+The inode of the socket is searched within /proc/net, then within the file descriptors of the processes:
+processes of other users cannot be analyzed, and "" is returned for them.
+*/
+func listeningProcess(port publishedPort) string {
+	if runtime.GOOS != "linux" {
+		return ""
+	}
+	inodes := make(map[string]bool)
+	for _, table := range []string{port.Proto, port.Proto + "6"} {
+		content, err := ioutil.ReadFile("/proc/net/" + table)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(content), "\n")[1:] {
+			// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+			fields := strings.Fields(line)
+			if len(fields) < 10 {
+				continue
+			}
+			local := strings.SplitN(fields[1], ":", 2)
+			if len(local) != 2 {
+				continue
+			}
+			local_port, _ := strconv.ParseInt(local[1], 16, 32)
+			// 0A is the LISTEN state of tcp sockets, 07 the unconnected state of udp ones
+			if int(local_port) == port.HostPort && (fields[3] == "0A" || (port.Proto == "udp" && fields[3] == "07")) {
+				inodes["socket:["+fields[9]+"]"] = true
+			}
+		}
+	}
+	if len(inodes) == 0 {
+		return ""
+	}
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+	for _, fd := range fds {
+		if link, err := os.Readlink(fd); err == nil && inodes[link] {
+			pid := strings.Split(fd, string(os.PathSeparator))[2]
+			comm, _ := ioutil.ReadFile(filepath.Join("/proc", pid, "comm"))
+			return fmt.Sprintf("%s (pid %s)", strings.TrimSpace(string(comm)), pid)
+		}
+	}
+	return ""
+}
+
+// nextFreePort returns the first port following the given one which is neither used by a process nor by a container
+func nextFreePort(port publishedPort, containers map[string]containerPorts) int {
+	for candidate := port.HostPort + 1; candidate <= 65535 && candidate <= port.HostPort+100; candidate++ {
+		free_port := port
+		free_port.HostPort = candidate
+		used := false
+		for _, container := range containers {
+			for _, other := range container.Ports {
+				used = used || free_port.conflicts(other)
+			}
+		}
+		if !used && !portInUse(free_port) {
+			return candidate
+		}
+	}
+	return 0
+}
+
+/*
+CheckPorts verifies, before starting the container of a definition, that the ports of the host it publishes are free.
+For each conflict, the returned error describes the definition, container or process holding the port, and suggests the next free port.
+*/
+func CheckPorts(containerManagerCmd string, containerName string) error {
+	ports, err := DefinitionPorts(containerName)
+	if err != nil || len(ports) == 0 {
+		// configurations which cannot be analyzed are left to the runtime
+		return nil
+	}
+	containers, err := runningContainerPorts(containerManagerCmd)
+	if err != nil {
+		return err
+	}
+	own_container := ContainerName(containerManagerCmd, containerName)
+	var conflicts []string
+	for _, port := range ports {
+		holder := ""
+		for name, container := range containers {
+			if name == own_container || container.Definition == containerName {
+				continue
+			}
+			for _, other := range container.Ports {
+				if !port.conflicts(other) {
+					continue
+				}
+				switch {
+				case container.Definition != "":
+					holder = "the definition '" + container.Definition + "'"
+					if name != container.Definition {
+						holder += " (container '" + name + "')"
+					}
+				case ConfigType(name) == CONFTYPECONTAINER:
+					// containers run before the definitions were tracked by label
+					holder = "the definition '" + name + "'"
+				default:
+					holder = "the container '" + name + "'"
+				}
+			}
+		}
+		if holder == "" && portInUse(port) {
+			holder = "another process"
+			if process := listeningProcess(port); process != "" {
+				holder = "the process " + process
+			}
+		}
+		if holder == "" {
+			continue
+		}
+		conflict := fmt.Sprintf("port %s is already used by %s", port, holder)
+		if free := nextFreePort(port, containers); free > 0 {
+			host := strconv.Itoa(free)
+			if port.HostIP != "" {
+				host = net.JoinHostPort(port.HostIP, host)
+			}
+			conflict += fmt.Sprintf(". Port %d is free: use e.g. '-p=%s:%s'", free, host, port.ContainerPort)
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("the container '%s' cannot be started:\n  %s", containerName, strings.Join(conflicts, "\n  "))
+	}
+	return nil
+}
@@ -7,4 +7,5 @@ require (
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/spf13/viper v1.7.1
 	github.com/yalp/jsonpath v0.0.0-20180802001716-5cc68e5049a0
+	golang.org/x/term v0.0.0-20210422114643-f5beecf764ed
 )
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HISTORYFILE is the file within the state dir where the commands executed by startainer are appended, one JSON object per line
+const HISTORYFILE string = "history.jsonl"
+
+// historyEntry is a command executed by startainer, as recorded within the history
+type historyEntry struct {
+	Time       time.Time `json:"time"`
+	Definition string    `json:"definition,omitempty"`
+	Status     string    `json:"status,omitempty"` // the status of the definition when the action was decided
+	Action     string    `json:"action"`           // e.g. 'run', 'image pull' or 'compose up'
+	Command    []string  `json:"command"`
+	Dir        string    `json:"dir,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	ExitCode   int       `json:"exit_code"`
+}
+
+var (
+	historyMutex sync.Mutex
+	// commandDefinitions maps the commands registered with forDefinition to their definition
+	commandDefinitions = make(map[*exec.Cmd]string)
+	// resolvedStatuses holds the last status resolved for each definition
+	resolvedStatuses = make(map[string]string)
+)
+
+// forDefinition registers the definition a command is executed for, so that it is recorded within the history
+func forDefinition(cmd *exec.Cmd, definition string) *exec.Cmd {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+	commandDefinitions[cmd] = definition
+	return cmd
+}
+
+// resolveStatus keeps the status resolved for a definition, which is recorded within the history with the following actions
+func resolveStatus(definition string, status string) {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+	resolvedStatuses[definition] = status
+}
+
+// historyAction returns the action performed by a command line, e.g. 'run', 'image pull' or 'compose up'
+func historyAction(args []string) string {
+	compose := strings.Contains(filepath.Base(args[0]), "compose")
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "compose":
+			compose = true
+		case strings.HasPrefix(arg, "-"):
+		case compose:
+			// the first argument following the options of compose, e.g. the compose files, which is a command of compose
+			if IsIn(arg, []string{"up", "down", "start", "stop", "restart", "rm", "pull", "exec", "run", "logs", "build", "create", "kill"}) {
+				return "compose " + arg
+			}
+		case (arg == "image" || arg == "container") && i+1 < len(args):
+			return arg + " " + args[i+1]
+		default:
+			return arg
+		}
+	}
+	return strings.Join(args[1:], " ")
+}
+
+/*
+recordHistory appends a command executed by startainer to the history, with the definition it was executed for, the status
+which the action was decided upon, its duration and its exit code (-1 if it could not be executed or was killed by a signal).
+Failing to record the history does not affect the command.
+*/
+func recordHistory(cmd *exec.Cmd, started time.Time, err error) {
+	historyMutex.Lock()
+	definition := commandDefinitions[cmd]
+	delete(commandDefinitions, cmd)
+	status := resolvedStatuses[definition]
+	historyMutex.Unlock()
+
+	entry := historyEntry{
+		Time:       started,
+		Definition: definition,
+		Status:     status,
+		Action:     historyAction(cmd.Args),
+		Command:    cmd.Args,
+		Dir:        cmd.Dir,
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+	var exitError *exec.ExitError
+	if errors.As(err, &exitError) {
+		entry.ExitCode = exitError.ExitCode()
+	} else if err != nil {
+		entry.ExitCode = -1
+	}
+	if err := appendHistory(entry); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: impossible to record the history. %s\n", err)
+	}
+}
+
+// appendHistory appends an entry to the history file within the state dir
+func appendHistory(entry historyEntry) error {
+	dir, err := StateDir()
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+	f, err := os.OpenFile(filepath.Join(dir, HISTORYFILE), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// readHistory returns the entries of the history, oldest first. Lines which cannot be read are skipped.
+func readHistory() ([]historyEntry, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(dir, HISTORYFILE))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []historyEntry
+	scanner := bufio.NewScanner(f)
+	// command lines can be long
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
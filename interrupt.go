@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/viper"
+)
+
+/*
+interruptAction returns what is done when startainer is interrupted (SIGINT or SIGTERM) while attached to the container or
+compose stack of a definition, as set by its 'on_interrupt' config:
+  - 'forward' (default): the signal is only forwarded to the runtime, which handles it;
+  - 'stop': the container, or the targeted services of the compose stack, are also stopped gracefully with 'stop',
+    so that nothing is left half-stopped once the runtime gives up.
+
+A nil function is returned if nothing has to be done besides forwarding the signal.
+*/
+func interruptAction(containerManagerCmd string, definition string, services []string) (func(), error) {
+	switch setting := strings.ToLower(viper.GetString(definition + ".on_interrupt")); setting {
+	case "", "forward":
+		return nil, nil
+	case "stop":
+		return func() {
+			log.Printf("Interrupted: stopping '%s' gracefully", definition)
+			var err error
+			if ConfigType(definition) == CONFTYPECOMPOSE {
+				err = ComposeStop(containerManagerCmd, definition, services)
+			} else {
+				err = ContainerStop(containerManagerCmd, definition)
+			}
+			if err != nil {
+				log.Print(err)
+			}
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid 'on_interrupt' configuration '%s' for '%s': use 'forward' or 'stop'", setting, definition)
+	}
+}
+
+// startAttached returns true if the parameters of 'start' attach the output of the container, e.g. '-a' or '-ai'
+func startAttached(start_args []string) bool {
+	for _, arg := range start_args {
+		if !strings.HasPrefix(arg, "-") {
+			return false
+		}
+		if arg == "--attach" || (!strings.HasPrefix(arg, "--") && strings.Contains(arg, "a")) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+exitOnError terminates startainer if an error occurred. When the runtime terminated with an exit code, e.g. because the
+session within the container ended with an error or was interrupted, a summary is printed out and startainer exits with
+the same exit code, instead of reporting the raw error.
+*/
+func exitOnError(err error) {
+	if err == nil {
+		return
+	}
+	var exitError *exec.ExitError
+	if !errors.As(err, &exitError) {
+		log.Fatal(err)
+	}
+	code := exitError.ExitCode()
+	if status, ok := exitError.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		// the runtime itself was terminated by a signal: exit as shells do
+		code = 128 + int(status.Signal())
+	}
+	if sig := interruption(); sig != nil {
+		log.Printf("Interrupted (%s): the runtime terminated with exit code %d", sig, code)
+	} else {
+		log.Printf("The runtime terminated with exit code %d", code)
+	}
+	os.Exit(code)
+}
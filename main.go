@@ -9,7 +9,6 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
-	"sort"
 	"strings"
 
 	"github.com/fatih/color"
@@ -17,11 +16,15 @@ import (
 )
 
 const (
-	VERSION string = "3.0.0" // version of the script. To be updated after each sensible update
+	VERSION string = "3.1.0" // version of the script. To be updated after each sensible update
 	// Common status codes for containers/images
 	MISSING             string = "missing"
 	STOPPED             string = "stopped"
 	RUNNING             string = "running"
+	PARTIAL             string = "partial"    // some of the services of a compose stack are running
+	RESTARTING          string = "restarting" // the container, or a service of a compose stack, is being restarted
+	UNHEALTHY           string = "unhealthy"  // running, but the health check is failing
+	EXITED              string = "exited"     // stopped with a non-zero exit code, see ExitedStatus
 	IMAGE_EXISTING      string = "image_existing"
 	ERROR               string = "error"
 	COMPOSEFILENOTFOUND string = "compose file missing"
@@ -46,9 +49,27 @@ var (
 	bold   = color.New(color.Bold).SprintFunc()
 )
 
+// ExitedStatus returns the status of a container which stopped with a non-zero exit code, e.g. "exited(137)"
+func ExitedStatus(exitCode int) string {
+	return fmt.Sprintf("%s(%d)", EXITED, exitCode)
+}
+
+// IsExited returns true if the status is the one of a container which stopped with a non-zero exit code
+func IsExited(status string) bool {
+	return strings.HasPrefix(status, EXITED)
+}
+
+// IsRunning returns true if the status implies that at least one container is running
+func IsRunning(status string) bool {
+	return IsIn(status, []string{RUNNING, PARTIAL, RESTARTING, UNHEALTHY})
+}
+
 // styleStatus analyzes the status of a container
 // and returns a colored stirng string corresponding to it
 func styleStatus(status string) string {
+	if IsExited(status) {
+		return red(status)
+	}
 	switch status {
 	case MISSING:
 		return red(status)
@@ -56,6 +77,10 @@ func styleStatus(status string) string {
 		return yellow(status)
 	case RUNNING:
 		return green(status)
+	case PARTIAL, RESTARTING:
+		return yellow(status)
+	case UNHEALTHY:
+		return red(status)
 	case COMPOSEFILENOTFOUND:
 		return red(status)
 	default:
@@ -63,42 +88,57 @@ func styleStatus(status string) string {
 	}
 }
 
+// DefinitionStatus returns the status of the container or compose stack of a definition,
+// with the default services of compose definitions.
+func DefinitionStatus(containerManagerCmd string, definition string) (status string, err error) {
+	switch ConfigType(definition) {
+	case CONFTYPECONTAINER:
+		return ContainerStatus(containerManagerCmd, definition, false)
+	case CONFTYPECOMPOSE:
+		return ComposeStatus(containerManagerCmd, definition, ComposeServices(definition, nil), false)
+	default:
+		return ERROR, fmt.Errorf("impossible to discern type of configuration for '%s'", definition)
+	}
+}
+
 func ListConfigs(containerManagerCmd string) {
-	// create empty map of string->boolean
-	statusMap := make(map[string]bool)
-	// populate the map of all the available container definitions
-	containerDefinitions := viper.AllKeys()
-	sort.Strings(containerDefinitions)
-	// "settings" MUST NOT be analyzed, as this is use as global configuration
-	// threfore, it is marked as already analyzed here :-)
-	statusMap["settings"] = true
+	listDefinitions(containerManagerCmd, DefinitionNames())
+}
+
+// listDefinitions prints out the given definitions with their type and status
+func listDefinitions(containerManagerCmd string, definitions []string) {
 	log.Print("The available container definitions are:")
-	for _, key := range containerDefinitions {
-		// key looks like: 'pagvpn.run', 'pagvpn.exec', 'splunk80.run', ...
-		definition := strings.SplitN(key, ".", 2)[0]
-		// if this definition has not been analyzed yet
-		if _, ok := statusMap[definition]; !ok {
-			if ConfigType(definition) == CONFTYPECONTAINER {
-				// get info about docker container configuration
-				status, err := ContainerStatus(containerManagerCmd, definition, false)
-				if err != nil {
-					log.Fatal(err)
-				}
-				// print out the definition
-				log.Printf("  - %-15s (container status: %s)", definition, styleStatus(status))
-			} else {
-				// get info about docker docker compose configuration
-				status, err := ComposeStatus(containerManagerCmd, definition, false)
-				if err != nil {
-					log.Fatal(err)
-				}
-				// print out the definition
-				log.Printf("  - %-15s (compose status: %s)", definition, styleStatus(status))
-			}
-			// tracks that this definition was already printed out
-			statusMap[definition] = true
+	for _, definition := range definitions {
+		status, err := DefinitionStatus(containerManagerCmd, definition)
+		if err != nil {
+			log.Fatal(err)
 		}
+		// print out the definition
+		log.Printf("  - %-15s (%s status: %s)", definition, ConfigType(definition), styleStatus(status))
+	}
+}
 
+// StopDefinition stops the container of a container definition, or brings down a compose stack.
+// If services of the compose definition are targeted, these are just stopped.
+func StopDefinition(containerManagerCmd string, definitionName string, services []string) error {
+	switch ConfigType(definitionName) {
+	case CONFTYPECONTAINER:
+		status, err := ContainerStatus(containerManagerCmd, definitionName, true)
+		if err != nil {
+			return err
+		}
+		if !IsRunning(status) {
+			log.Printf("The container '%s' is %s, nothing to stop", definitionName, styleStatus(status))
+			return nil
+		}
+		return ContainerStop(containerManagerCmd, definitionName)
+	case CONFTYPECOMPOSE:
+		if len(services) > 0 {
+			return ComposeStop(containerManagerCmd, definitionName, services)
+		}
+		return ComposeDown(containerManagerCmd, definitionName, nil, false)
+	default:
+		return fmt.Errorf("impossible to discern type of configuration for '%s'", definitionName)
 	}
 }
 
@@ -132,6 +172,7 @@ func main() {
 		defaultConfigFile   string
 		containerManagerCmd string
 		definitionName      string
+		services            []string
 		configFile          string
 		flagListConfigs     bool
 		flagVersion         bool
@@ -168,6 +209,15 @@ func main() {
 	flag.BoolVar(&flagChangeLog, "changelog", false, "If provided, print out the complete changelog and then exits")
 	flag.BoolVar(&flagQuiet, "quiet", false, "Activate quiet mode: do not emit any internal logging")
 	flag.BoolVar(&flagNoColor, "no-color", false, "Disable colored output")
+	flag.StringVar(&execProfile, "as", "", "Name of the profile of the 'exec' configuration used to attach to a running container or compose stack, e.g. 'root'. It can also follow the definition: 'startainer alpine --as root'")
+	flag.BoolVar(&dryRun, "dry-run", false, "Perform the status checks and print out the decisions and the commands which would be executed, without altering any container, image or compose stack")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n  %s [flags] <definition> [additional parameters for 'run' or 'up']\n  %s [flags] <command> [command parameters]\n", os.Args[0], os.Args[0])
+		printCommands(flag.CommandLine.Output())
+		fmt.Fprint(flag.CommandLine.Output(), "Flags:\n")
+		flag.PrintDefaults()
+	}
 
 	// parse cmd-line parameters
 	flag.Parse()
@@ -211,6 +261,16 @@ func main() {
 		log.Printf("Set %s as container runtime", containerManagerCmd)
 	}
 
+	if flag.NArg() > 0 {
+		if command, ok := subcommands[flag.Arg(0)]; ok {
+			if ConfigType(flag.Arg(0)) != CONFTYPEUNKNOWN {
+				log.Printf("Warning: '%s' is both a command and a definition name. Executing the command", flag.Arg(0))
+			}
+			command.run(containerManagerCmd, flag.Args()[1:])
+			return
+		}
+	}
+
 	if flagListConfigs && flag.NArg() > 0 {
 		ListSingleContainer(containerManagerCmd, flag.Arg(0))
 		return
@@ -220,23 +280,49 @@ func main() {
 		return
 	}
 
+	target := flag.Arg(0)
 	if flag.NArg() == 0 {
-		log.Fatal("Specify the name of a container as defined within the configuration file, or `-l` to list all definitions")
+		// let the user choose the definition interactively
+		picked, err := PickDefinition(containerManagerCmd)
+		switch err {
+		case nil:
+			target = picked
+		case errNoTerminal:
+			log.Fatal("Specify the name of a container as defined within the configuration file, or `-l` to list all definitions")
+		case errPickerCancelled:
+			log.Print(err)
+			return
+		default:
+			log.Fatal(err)
+		}
 	}
 
-	definitionName = flag.Arg(0)
+	// the definition might target services of a compose definition, e.g.: composeexample:web
+	definitionName, services, err := SplitTarget(target)
+	if err != nil {
+		log.Fatal(err)
+	}
+	services = ComposeServices(definitionName, services)
 	//.Args() is an array of the remaining parameters provided, which do not have a name
 	if flag.NArg() > 1 {
-		additionalArgs = flag.Args()[1:]
+		additionalArgs = extractExecProfile(flag.Args()[1:])
+	}
+
+	if flagDown {
+		if err := StopDefinition(containerManagerCmd, definitionName, services); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
 	switch ConfigType(definitionName) {
 	case CONFTYPECONTAINER:
-		ManageContainer(containerManagerCmd, definitionName, additionalArgs)
+		err = ManageContainer(containerManagerCmd, definitionName, additionalArgs)
 	case CONFTYPECOMPOSE:
-		ManageCompose(containerManagerCmd, definitionName, additionalArgs)
+		err = ManageCompose(containerManagerCmd, definitionName, services, additionalArgs)
 	default:
-		log.Fatalf("Impossible to discern type of configuration for '%s'", definitionName)
+		err = fmt.Errorf("impossible to discern type of configuration for '%s'", definitionName)
 	}
+	exitOnError(err)
 
 }
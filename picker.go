@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+var (
+	errNoTerminal      = errors.New("the interactive picker needs a terminal")
+	errPickerCancelled = errors.New("no definition selected")
+)
+
+// pickerItem is a definition listed by the interactive picker
+type pickerItem struct {
+	name   string
+	kind   string
+	status string
+}
+
+/*
+fuzzyScore tells how well the query matches the name: the characters of the query must appear within the name
+in the same order, but not necessarily next to each other. Lower scores are better matches, -1 means no match.
+*/
+func fuzzyScore(name string, query string) int {
+	name, query = strings.ToLower(name), strings.ToLower(query)
+	switch {
+	case query == "":
+		return 0
+	case strings.HasPrefix(name, query):
+		return 0
+	case strings.Contains(name, query):
+		return 1
+	}
+	// subsequence: the score grows with the characters skipped between the matching ones
+	score, pos := 2, 0
+	for _, c := range query {
+		i := strings.IndexRune(name[pos:], c)
+		if i < 0 {
+			return -1
+		}
+		score += i
+		pos += i + 1
+	}
+	return score
+}
+
+// filterItems returns the items matching the query, the best matches first
+func filterItems(items []pickerItem, query string) []pickerItem {
+	var filtered []pickerItem
+	scores := make(map[string]int)
+	for _, item := range items {
+		if score := fuzzyScore(item.name, query); score >= 0 {
+			scores[item.name] = score
+			filtered = append(filtered, item)
+		}
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return scores[filtered[i].name] < scores[filtered[j].name]
+	})
+	return filtered
+}
+
+/*
+PickDefinition shows an interactive list of the definitions with their status, filtered while typing,
+and returns the definition selected with Enter. Arrows (or Ctrl-P/Ctrl-N) move the selection, Esc or Ctrl-C cancel.
+The list is drawn on stderr, and errNoTerminal is returned if stdin or stderr is not a terminal.
+*/
+func PickDefinition(containerManagerCmd string) (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return "", errNoTerminal
+	}
+	var items []pickerItem
+	fmt.Fprint(os.Stderr, "Retrieving the status of the definitions...")
+	for _, definition := range DefinitionNames() {
+		status, err := DefinitionStatus(containerManagerCmd, definition)
+		if err != nil {
+			status = ERROR
+		}
+		items = append(items, pickerItem{name: definition, kind: ConfigType(definition), status: status})
+	}
+	fmt.Fprint(os.Stderr, "\r\x1b[K")
+	if len(items) == 0 {
+		return "", fmt.Errorf("no definitions available within the configuration file")
+	}
+
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return "", errNoTerminal
+	}
+	defer term.Restore(int(os.Stdin.Fd()), state)
+
+	query, selected, drawn := "", 0, 0
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		filtered := filterItems(items, query)
+		if selected >= len(filtered) {
+			selected = len(filtered) - 1
+		}
+		if selected < 0 {
+			selected = 0
+		}
+		drawn = drawPicker(filtered, query, selected, drawn)
+
+		key, err := reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch key {
+		case '\r', '\n':
+			clearPicker(drawn)
+			if len(filtered) == 0 {
+				return "", errPickerCancelled
+			}
+			return filtered[selected].name, nil
+		case 3: // Ctrl-C
+			clearPicker(drawn)
+			return "", errPickerCancelled
+		case 27: // Esc, or the beginning of the sequence of an arrow key
+			if reader.Buffered() == 0 {
+				clearPicker(drawn)
+				return "", errPickerCancelled
+			}
+			sequence := make([]byte, 2)
+			reader.Read(sequence)
+			switch string(sequence) {
+			case "[A", "OA":
+				selected--
+			case "[B", "OB":
+				selected++
+			}
+		case 16: // Ctrl-P
+			selected--
+		case 14: // Ctrl-N
+			selected++
+		case 127, 8: // Backspace
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				selected = 0
+			}
+		case 21: // Ctrl-U
+			query, selected = "", 0
+		default:
+			if key >= 32 && key < 127 {
+				query += string(key)
+				selected = 0
+			}
+		}
+	}
+}
+
+// drawPicker draws the query and the list of definitions, replacing the lines drawn before. It returns the number of drawn lines.
+func drawPicker(items []pickerItem, query string, selected int, previous int) int {
+	var b strings.Builder
+	if previous > 0 {
+		// move back to the first line drawn before
+		fmt.Fprintf(&b, "\x1b[%dA", previous)
+	}
+	b.WriteString("\r\x1b[J")
+	_, height, err := term.GetSize(int(os.Stderr.Fd()))
+	if err != nil || height < 5 {
+		height = 25
+	}
+	// scroll the list so that the selected item is visible
+	rows := height - 2
+	first := 0
+	if selected >= rows {
+		first = selected - rows + 1
+	}
+	lines := 0
+	for i := first; i < len(items) && i < first+rows; i++ {
+		item := items[i]
+		line := fmt.Sprintf("  %-20s %-10s %s", item.name, item.kind, styleStatus(item.status))
+		if i == selected {
+			line = bold("> ") + bold(fmt.Sprintf("%-20s", item.name)) + fmt.Sprintf(" %-10s %s", item.kind, styleStatus(item.status))
+		}
+		b.WriteString(line + "\r\n")
+		lines++
+	}
+	if len(items) == 0 {
+		b.WriteString("  no matching definitions\r\n")
+		lines++
+	}
+	fmt.Fprintf(&b, "%s %s", blue(fmt.Sprintf("[%d] Start:", len(items))), query)
+	fmt.Fprint(os.Stderr, b.String())
+	return lines
+}
+
+// clearPicker removes the lines drawn by drawPicker
+func clearPicker(drawn int) {
+	if drawn > 0 {
+		fmt.Fprintf(os.Stderr, "\x1b[%dA", drawn)
+	}
+	fmt.Fprint(os.Stderr, "\r\x1b[J")
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// dryRun is set by the '-dry-run' command-line flag: status checks are performed,
+// but commands altering containers, images or compose stacks are only printed out.
+var dryRun bool
+
+// dryRunLog prints the dry-run information. It does not use the standard logger, which is discarded in quiet mode.
+var dryRunLog = log.New(os.Stderr, "> [dry-run] ", 0)
+
+// explain prints out, in dry-run mode only, a step of the decision path followed by the tool
+func explain(format string, v ...interface{}) {
+	if dryRun {
+		dryRunLog.Printf(format, v...)
+	}
+}
+
+// STDERRTAILSIZE is the number of bytes of the stderr of a command kept by stderrTail
+const STDERRTAILSIZE int = 8 * 1024
+
+/*
+stderrTail captures the last STDERRTAILSIZE bytes written to the stderr of a command: enough to classify the errors
+of the runtime, without accumulating the whole output of a container attached for hours.
+*/
+type stderrTail struct {
+	buf []byte
+}
+
+func (t *stderrTail) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if excess := len(t.buf) - STDERRTAILSIZE; excess > 0 {
+		copy(t.buf, t.buf[excess:])
+		t.buf = t.buf[:STDERRTAILSIZE]
+	}
+	return len(p), nil
+}
+
+func (t *stderrTail) String() string {
+	return string(t.buf)
+}
+
+/*
+teeStderr shows the stderr of a command to the user while it is written, so that progress and warnings of long
+operations are visible, and captures its tail as well within errb, so that errors can be classified once the command terminates.
+*/
+func teeStderr(cmd *exec.Cmd, errb *stderrTail) {
+	cmd.Stderr = io.MultiWriter(os.Stderr, errb)
+}
+
+/*
+runCommand executes a command which alters the state of containers, images or compose stacks.
+In dry-run mode, the command is printed out instead of being executed, and no error is returned.
+Commands which only read the state, such as 'inspect' or 'ps', must be executed directly with cmd.Run().
+*/
+func runCommand(cmd *exec.Cmd) error {
+	return runInterruptible(cmd, nil)
+}
+
+var (
+	interruptMutex sync.Mutex
+	// interruptedBy is the first signal which interrupted the command executed with runInterruptible, or nil
+	interruptedBy os.Signal
+	// runningCommands is the number of commands being executed with runInterruptible, e.g. 2 while 'on_interrupt' stops a container
+	runningCommands int
+)
+
+// interruption returns the first signal which interrupted the last command executed with runInterruptible, or nil
+func interruption() os.Signal {
+	interruptMutex.Lock()
+	defer interruptMutex.Unlock()
+	return interruptedBy
+}
+
+/*
+runInterruptible executes a command as runCommand does, recording it within the history, forwarding SIGINT, SIGTERM and SIGWINCH to it until it terminates,
+instead of letting startainer die and leave the command behind. When startainer is in the foreground of a terminal, Ctrl-C is not
+forwarded, as the command receives it from the terminal already. If onInterrupt is not nil, it is executed in the background upon the first SIGINT
+or SIGTERM, e.g. to stop gracefully the container the command is attached to.
+The interruption is reset by each command, unless it is executed while another one is running, e.g. by onInterrupt.
+*/
+func runInterruptible(cmd *exec.Cmd, onInterrupt func()) error {
+	if dryRun {
+		if cmd.Dir != "" {
+			dryRunLog.Printf("would execute, within folder '%s':\n    %s", cmd.Dir, strings.Join(cmd.Args, " "))
+		} else {
+			dryRunLog.Printf("would execute:\n    %s", strings.Join(cmd.Args, " "))
+		}
+		return nil
+	}
+	interruptMutex.Lock()
+	if runningCommands == 0 {
+		interruptedBy = nil
+	}
+	runningCommands++
+	interruptMutex.Unlock()
+	defer func() {
+		interruptMutex.Lock()
+		runningCommands--
+		interruptMutex.Unlock()
+	}()
+
+	started := time.Now()
+	if err := cmd.Start(); err != nil {
+		recordHistory(cmd, started, err)
+		return err
+	}
+	signals := make(chan os.Signal, 4)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	// the command is within the process group of startainer: when it is the foreground group of the terminal,
+	// Ctrl-C reaches the command whatever its input is
+	fromTerminal := receivesTerminalInterrupt()
+	for {
+		select {
+		case err := <-done:
+			recordHistory(cmd, started, err)
+			return err
+		case sig := <-signals:
+			if sig == os.Interrupt || sig == syscall.SIGTERM {
+				interruptMutex.Lock()
+				first := interruptedBy == nil
+				if first {
+					interruptedBy = sig
+				}
+				if first && onInterrupt != nil {
+					// the commands of onInterrupt must not reset the interruption, even if this one terminates first
+					runningCommands++
+					go func() {
+						onInterrupt()
+						interruptMutex.Lock()
+						runningCommands--
+						interruptMutex.Unlock()
+					}()
+				}
+				interruptMutex.Unlock()
+			}
+			if sig == os.Interrupt && fromTerminal {
+				continue
+			}
+			// signals cannot be sent on every platform: the command is then left to terminate on its own
+			cmd.Process.Signal(sig)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStderrTail(t *testing.T) {
+	var tail stderrTail
+	tail.Write([]byte("first line\n"))
+	if got := tail.String(); got != "first line\n" {
+		t.Errorf("got %q, want %q", got, "first line\n")
+	}
+	for i := 0; i < 1000; i++ {
+		tail.Write([]byte(strings.Repeat("x", 99) + "\n"))
+	}
+	tail.Write([]byte("Error: port is already allocated\n"))
+	got := tail.String()
+	if len(got) != STDERRTAILSIZE {
+		t.Errorf("kept %d bytes, want %d", len(got), STDERRTAILSIZE)
+	}
+	if !strings.HasSuffix(got, "x\nError: port is already allocated\n") {
+		t.Errorf("the tail does not end with the last write: %q", got[len(got)-60:])
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// runValueFlags lists the flags of 'docker run' which take a value, with the long name corresponding to the short ones.
+// Flags not listed here are considered boolean, unless they are provided as '--flag=value'.
+var runValueFlags = map[string]string{
+	"-a": "--attach", "-c": "--cpu-shares", "-e": "--env", "-h": "--hostname", "-l": "--label",
+	"-m": "--memory", "-p": "--publish", "-u": "--user", "-v": "--volume", "-w": "--workdir",
+	"--add-host": "", "--attach": "", "--blkio-weight": "", "--cap-add": "", "--cap-drop": "", "--cgroup-parent": "",
+	"--cgroupns": "", "--cidfile": "", "--cpu-period": "", "--cpu-quota": "", "--cpu-shares": "", "--cpus": "",
+	"--cpuset-cpus": "", "--cpuset-mems": "", "--detach-keys": "", "--device": "", "--device-cgroup-rule": "",
+	"--dns": "", "--dns-option": "", "--dns-search": "", "--domainname": "", "--entrypoint": "", "--env": "",
+	"--env-file": "", "--expose": "", "--gpus": "", "--group-add": "", "--health-cmd": "", "--health-interval": "",
+	"--health-retries": "", "--health-start-period": "", "--health-timeout": "", "--hostname": "", "--ip": "",
+	"--ip6": "", "--ipc": "", "--isolation": "", "--kernel-memory": "", "--label": "", "--label-file": "",
+	"--link": "", "--log-driver": "", "--log-opt": "", "--mac-address": "", "--memory": "", "--memory-reservation": "",
+	"--memory-swap": "", "--memory-swappiness": "", "--mount": "", "--name": "", "--net": "--network", "--network": "",
+	"--network-alias": "", "--pid": "", "--pids-limit": "", "--platform": "", "--publish": "", "--pull": "",
+	"--restart": "", "--runtime": "", "--security-opt": "", "--shm-size": "", "--stop-signal": "", "--stop-timeout": "",
+	"--storage-opt": "", "--sysctl": "", "--tmpfs": "", "--ulimit": "", "--user": "", "--userns": "", "--uts": "",
+	"--volume": "", "--volume-driver": "", "--volumes-from": "", "--workdir": "",
+}
+
+// runBoolFlags maps the short boolean flags of 'docker run' to their long names
+var runBoolFlags = map[string]string{
+	"-d": "--detach", "-i": "--interactive", "-t": "--tty", "-P": "--publish-all", "-q": "--quiet",
+}
+
+// runOption is a single option provided to 'docker run', e.g. '-p=8000:8000' or '-ti'
+type runOption struct {
+	Flag     string // the flag as it was written, e.g. '-p', '--volume' or '-ti'
+	Value    string
+	HasValue bool
+}
+
+// names returns the long names of the flags set by the option.
+// Combined short flags, such as '-ti', set more than one flag.
+func (opt runOption) names() []string {
+	if !strings.HasPrefix(opt.Flag, "--") && len(opt.Flag) > 2 {
+		var names []string
+		for _, c := range opt.Flag[1:] {
+			names = append(names, canonicalRunFlag("-"+string(c)))
+		}
+		return names
+	}
+	return []string{canonicalRunFlag(opt.Flag)}
+}
+
+// arg returns the option in the format used within the configuration file, e.g. '-p=8000:8000'
+func (opt runOption) arg() string {
+	if opt.HasValue {
+		return opt.Flag + "=" + opt.Value
+	}
+	return opt.Flag
+}
+
+// canonicalRunFlag returns the long name of a flag of 'docker run', e.g. '--publish' for '-p'
+func canonicalRunFlag(flag string) string {
+	if long := runValueFlags[flag]; long != "" {
+		return long
+	}
+	if long := runBoolFlags[flag]; long != "" {
+		return long
+	}
+	return flag
+}
+
+/*
+runSpec is the analyzed content of the arguments of 'docker run', as found within the 'run' configuration of a definition:
+the options, the image and the command executed within the container.
+*/
+type runSpec struct {
+	Options []runOption
+	Image   string
+	Command []string
+}
+
+/*
+parseRunArgs analyzes the arguments of 'docker run'. The options can use both the formats '-p=8000:8000' and '-p 8000:8000'.
+The first argument which is not an option is the image, the following ones are the command executed within the container.
+*/
+func parseRunArgs(args []string) (runSpec, error) {
+	var spec runSpec
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || !strings.HasPrefix(arg, "-") || arg == "-" {
+			if arg == "--" {
+				i++
+			}
+			if i < len(args) {
+				spec.Image = args[i]
+				spec.Command = args[i+1:]
+			}
+			return spec, nil
+		}
+		// the config file might contain options such as '-v host-path:container-path' within a single item
+		if strings.HasPrefix(arg, "-") && strings.Contains(arg, " ") && !strings.Contains(strings.SplitN(arg, " ", 2)[0], "=") {
+			parts := strings.SplitN(arg, " ", 2)
+			arg = parts[0] + "=" + parts[1]
+		}
+		flag, value, has_value := arg, "", false
+		if strings.HasPrefix(arg, "--") {
+			if pos := strings.Index(arg, "="); pos > 0 {
+				flag, value, has_value = arg[:pos], arg[pos+1:], true
+			}
+		} else {
+			flag, value, has_value = splitShortFlags(arg, &spec)
+		}
+		if _, takes_value := runValueFlags[flag]; takes_value && !has_value {
+			if i+1 >= len(args) {
+				return spec, fmt.Errorf("flag '%s' requires a value", flag)
+			}
+			i++
+			value, has_value = args[i], true
+		}
+		spec.Options = append(spec.Options, runOption{Flag: flag, Value: value, HasValue: has_value})
+	}
+	return spec, fmt.Errorf("no image found within the arguments of 'run'")
+}
+
+/*
+splitShortFlags analyzes an argument made of short flags, such as '-ti', '-p=80:80', '-eFOO=bar' or '-dp 80:80'.
+The first short flag taking a value gets the remainder of the argument verbatim, without the '=' following it:
+the flags preceding it are added to the options of the spec, and the flag is returned with its value, if any.
+*/
+func splitShortFlags(arg string, spec *runSpec) (flag string, value string, has_value bool) {
+	shorts := arg[1:]
+	for j := 0; j < len(shorts); j++ {
+		if shorts[j] == '=' {
+			// the value of boolean flags, e.g. '-t=false'
+			return "-" + shorts[:j], shorts[j+1:], true
+		}
+		short := "-" + shorts[j:j+1]
+		if _, ok := runValueFlags[short]; !ok {
+			continue
+		}
+		if j > 0 {
+			spec.Options = append(spec.Options, runOption{Flag: "-" + shorts[:j]})
+		}
+		// the value of the flag is the remainder of the argument, or the next argument
+		rest := shorts[j+1:]
+		if rest == "" {
+			return short, "", false
+		}
+		return short, strings.TrimPrefix(rest, "="), true
+	}
+	return arg, "", false
+}
+
+// Has returns true if the flag, identified by its long name (e.g. '--detach'), is set
+func (spec runSpec) Has(name string) bool {
+	for _, opt := range spec.Options {
+		if IsIn(name, opt.names()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Values returns the values of all the occurrences of a flag, identified by its long name (e.g. '--publish')
+func (spec runSpec) Values(name string) []string {
+	var values []string
+	for _, opt := range spec.Options {
+		if opt.HasValue && canonicalRunFlag(opt.Flag) == name {
+			values = append(values, opt.Value)
+		}
+	}
+	return values
+}
+
+// Value returns the value of the last occurrence of a flag, identified by its long name, or "" if the flag is not set
+func (spec runSpec) Value(name string) string {
+	values := spec.Values(name)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// Args returns the arguments of 'docker run' in the format used within the configuration file,
+// including the image and the command
+func (spec runSpec) Args() []string {
+	var args []string
+	for _, opt := range spec.Options {
+		args = append(args, opt.arg())
+	}
+	if spec.Image != "" {
+		args = append(args, spec.Image)
+	}
+	return append(args, spec.Command...)
+}
+
+/*
+splitCommandLine splits a shell command line into its arguments, the way a POSIX shell would, supporting
+single and double quotes, escaping with backslashes and lines continued with a trailing backslash.
+Variables and sub-shells, such as $HOME or $(pwd), are not expanded.
+*/
+func splitCommandLine(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	in_arg := false
+	quote := rune(0)
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(c)
+			}
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else if c == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]) {
+				i++
+				if runes[i] != '\n' {
+					current.WriteRune(runes[i])
+				}
+			} else {
+				current.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			in_arg = true
+		case c == '\\':
+			if i+1 < len(runes) {
+				i++
+				// a backslash at the end of a line continues the command on the next line
+				if runes[i] == '\r' && i+1 < len(runes) && runes[i+1] == '\n' {
+					i++
+				}
+				if runes[i] != '\n' {
+					current.WriteRune(runes[i])
+					in_arg = true
+				}
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if in_arg {
+				args = append(args, current.String())
+				current.Reset()
+				in_arg = false
+			}
+		default:
+			current.WriteRune(c)
+			in_arg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote %c within command line", quote)
+	}
+	if in_arg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRunArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want runSpec
+	}{
+		{
+			name: "image only",
+			args: []string{"alpine"},
+			want: runSpec{Image: "alpine", Command: []string{}},
+		},
+		{
+			name: "image and command",
+			args: []string{"--rm", "alpine", "sh", "-c", "echo -t"},
+			want: runSpec{Options: []runOption{{Flag: "--rm"}}, Image: "alpine", Command: []string{"sh", "-c", "echo -t"}},
+		},
+		{
+			name: "values with equal sign, space and next argument",
+			args: []string{"-p=8000:80", "-v data:/data", "--name", "web", "--env=A=1", "nginx"},
+			want: runSpec{Options: []runOption{
+				{Flag: "-p", Value: "8000:80", HasValue: true},
+				{Flag: "-v", Value: "data:/data", HasValue: true},
+				{Flag: "--name", Value: "web", HasValue: true},
+				{Flag: "--env", Value: "A=1", HasValue: true},
+			}, Image: "nginx", Command: []string{}},
+		},
+		{
+			name: "short value flags attached to their value",
+			args: []string{"-eFOO=bar", "-lkey=val", "-p8080:80", "-e=A=B", "alpine"},
+			want: runSpec{Options: []runOption{
+				{Flag: "-e", Value: "FOO=bar", HasValue: true},
+				{Flag: "-l", Value: "key=val", HasValue: true},
+				{Flag: "-p", Value: "8080:80", HasValue: true},
+				{Flag: "-e", Value: "A=B", HasValue: true},
+			}, Image: "alpine", Command: []string{}},
+		},
+		{
+			name: "combined short flags",
+			args: []string{"-ti", "-dp", "80:80", "-dit", "-deFOO=bar", "alpine"},
+			want: runSpec{Options: []runOption{
+				{Flag: "-ti"},
+				{Flag: "-d"},
+				{Flag: "-p", Value: "80:80", HasValue: true},
+				{Flag: "-dit"},
+				{Flag: "-d"},
+				{Flag: "-e", Value: "FOO=bar", HasValue: true},
+			}, Image: "alpine", Command: []string{}},
+		},
+		{
+			name: "boolean flags with a value",
+			args: []string{"-t=false", "--detach=true", "alpine"},
+			want: runSpec{Options: []runOption{
+				{Flag: "-t", Value: "false", HasValue: true},
+				{Flag: "--detach", Value: "true", HasValue: true},
+			}, Image: "alpine", Command: []string{}},
+		},
+		{
+			name: "double dash before the image",
+			args: []string{"-d", "--", "-image"},
+			want: runSpec{Options: []runOption{{Flag: "-d"}}, Image: "-image", Command: []string{}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseRunArgs(test.args)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseRunArgsErrors(t *testing.T) {
+	for _, args := range [][]string{nil, {"-d"}, {"--rm", "-p"}, {"--name"}} {
+		if _, err := parseRunArgs(args); err == nil {
+			t.Errorf("no error for %q", args)
+		}
+	}
+}
+
+func TestRunSpecAccessors(t *testing.T) {
+	spec, err := parseRunArgs([]string{"-dit", "--name=first", "-eFOO=bar", "--env", "A=1", "--name", "web", "-p80:80", "alpine"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, name := range []string{"--detach", "--interactive", "--tty", "--env", "--publish"} {
+		if !spec.Has(name) {
+			t.Errorf("Has(%s) is false", name)
+		}
+	}
+	if spec.Has("--rm") {
+		t.Error("Has(--rm) is true")
+	}
+	if got := spec.Value("--name"); got != "web" {
+		t.Errorf("Value(--name) = %q, want %q", got, "web")
+	}
+	if got, want := spec.Values("--env"), []string{"FOO=bar", "A=1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Values(--env) = %q, want %q", got, want)
+	}
+	want := []string{"-dit", "--name=first", "-e=FOO=bar", "--env=A=1", "--name=web", "-p=80:80", "alpine"}
+	if got := spec.Args(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Args() = %q, want %q", got, want)
+	}
+	// the arguments written within the configuration file are parsed back into the same spec
+	again, err := parseRunArgs(spec.Args())
+	if err != nil || !reflect.DeepEqual(again, spec) {
+		t.Errorf("Args() parsed back into %+v (%v), want %+v", again, err, spec)
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{`docker run -d alpine`, []string{"docker", "run", "-d", "alpine"}},
+		{`docker run -e "A=hello world" -e 'B=$HOME' alpine`, []string{"docker", "run", "-e", "A=hello world", "-e", "B=$HOME", "alpine"}},
+		{"docker run \\\n  -v \"$(pwd)\":/srv \\\n  alpine", []string{"docker", "run", "-v", "$(pwd):/srv", "alpine"}},
+		{`echo a\ b "c\"d" ''`, []string{"echo", "a b", `c"d`, ""}},
+	}
+	for _, test := range tests {
+		got, err := splitCommandLine(test.line)
+		if err != nil {
+			t.Errorf("splitCommandLine(%q): unexpected error: %s", test.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitCommandLine(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+	if _, err := splitCommandLine(`echo "unterminated`); err == nil {
+		t.Error("no error for an unterminated quote")
+	}
+}
@@ -0,0 +1,31 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// forwardedSignals are the signals forwarded to the commands executed by runInterruptible
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGWINCH}
+
+/*
+receivesTerminalInterrupt returns true if the commands executed by startainer receive Ctrl-C from the terminal directly:
+the terminal sends SIGINT to its whole foreground process group, which the commands share with startainer, whatever their input is.
+Without a controlling terminal, or when startainer runs in the background, SIGINT is sent to startainer only, e.g. by 'kill'.
+*/
+func receivesTerminalInterrupt() bool {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return false
+	}
+	defer tty.Close()
+	var foreground int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, tty.Fd(), syscall.TIOCGPGRP, uintptr(unsafe.Pointer(&foreground))); errno != 0 {
+		return false
+	}
+	return int(foreground) == syscall.Getpgrp()
+}
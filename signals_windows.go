@@ -0,0 +1,16 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// forwardedSignals are the signals forwarded to the commands executed by runInterruptible.
+// Windows has no SIGWINCH: the console resizes are seen by the command directly.
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// receivesTerminalInterrupt returns true if the commands executed by startainer receive Ctrl-C from the terminal directly:
+// on windows, Ctrl-C is sent to all the processes attached to the console, and it is the only way startainer receives it.
+func receivesTerminalInterrupt() bool {
+	return true
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+/*
+terminalAllowed returns true if a terminal can be allocated to the container of a definition, as set by its 'tty' config:
+  - 'auto' (default): only if both the input and the output of startainer are terminals,
+    so that piping data in (e.g. 'cat data.csv | startainer de-utils import') or running within CI works;
+  - 'true': the configurations are used as they are;
+  - 'false': a terminal is never allocated.
+*/
+func terminalAllowed(definition string) (bool, error) {
+	switch setting := strings.ToLower(viper.GetString(definition + ".tty")); setting {
+	case "", "auto":
+		return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd())), nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid 'tty' configuration '%s' for '%s': use 'auto', 'true' or 'false'", setting, definition)
+	}
+}
+
+/*
+withoutTTY removes '-t' (also from combined flags such as '-ti') and '--tty' from the options of 'run' or 'exec', so that
+they do not fail with "the input device is not a TTY". Detached containers keep their terminal, as nothing is attached to them.
+The second return value is false if nothing was removed.
+*/
+func withoutTTY(args []string) ([]string, bool) {
+	spec, err := parseRunArgs(args)
+	if err != nil || !spec.Has("--tty") || spec.Has("--detach") {
+		return args, false
+	}
+	var options []runOption
+	for _, opt := range spec.Options {
+		switch {
+		case opt.HasValue || !IsIn("--tty", opt.names()):
+			options = append(options, opt)
+		case len(opt.Flag) > 2 && !strings.HasPrefix(opt.Flag, "--"):
+			// combined flags, e.g. '-ti' becomes '-i'
+			options = append(options, runOption{Flag: strings.Replace(opt.Flag, "t", "", 1)})
+		}
+	}
+	spec.Options = options
+	return spec.Args(), true
+}
+
+/*
+withoutStdin removes '-i' (also from combined flags such as '-ai') and '--interactive' from the options of 'start':
+the input of a container having a terminal can only be attached from a terminal.
+The second return value is false if nothing was removed.
+*/
+func withoutStdin(args []string) ([]string, bool) {
+	var kept []string
+	removed := false
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			// the container name and the ones following it
+			kept = append(kept, args[i:]...)
+			break
+		}
+		switch {
+		case arg == "-i" || arg == "--interactive":
+			removed = true
+		case !strings.HasPrefix(arg, "--") && strings.Contains(arg, "i"):
+			kept = append(kept, strings.Replace(arg, "i", "", 1))
+			removed = true
+		default:
+			kept = append(kept, arg)
+		}
+	}
+	return kept, removed
+}
+
+/*
+adjustTTY adapts the parameters of 'run', 'exec' or 'start' (given by 'command') of a container definition to the
+availability of a terminal, as set by the 'tty' config of the definition.
+*/
+func adjustTTY(definition string, command string, args []string) ([]string, error) {
+	allowed, err := terminalAllowed(definition)
+	if err != nil || allowed {
+		return args, err
+	}
+	if command == "start" {
+		// the terminal of the container was allocated by 'run'
+		if spec, err := parseRunArgs(RunConfig(definition)); err == nil && spec.Has("--tty") {
+			if adjusted, changed := withoutStdin(args); changed {
+				log.Print("No terminal is available: the input is not attached to the container, which has a terminal")
+				return adjusted, nil
+			}
+		}
+		return args, nil
+	}
+	adjusted, changed := withoutTTY(args)
+	if changed {
+		log.Printf("No terminal is available: '%s' is executed without allocating one", command)
+	}
+	return adjusted, nil
+}
+
+/*
+adjustComposeTTY adds '-T' to the parameters of 'compose exec' when no terminal can be allocated,
+as 'compose exec' allocates one by default.
+*/
+func adjustComposeTTY(composeConfName string, exec_args []string) ([]string, error) {
+	allowed, err := terminalAllowed(composeConfName)
+	if err != nil || allowed || IsIn("-T", exec_args) || IsIn("--no-TTY", exec_args) {
+		return exec_args, err
+	}
+	log.Print("No terminal is available: 'compose exec' is executed without allocating one")
+	return append([]string{"-T"}, exec_args...), nil
+}
@@ -2,8 +2,14 @@ package main
 
 import (
 	//_ "embed"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 
 	"github.com/mitchellh/go-homedir"
@@ -26,6 +32,159 @@ func ConfigType(configName string) string {
 	return CONFTYPEUNKNOWN
 }
 
+// DefinitionNames returns the sorted list of all the container and compose definitions
+// available within the configuration file. The "settings" section is not part of the list.
+func DefinitionNames() []string {
+	var names []string
+	// create empty map of string->boolean, to track definitions already found
+	found := make(map[string]bool)
+	// "settings" MUST NOT be analyzed, as this is use as global configuration
+	found["settings"] = true
+	for _, key := range viper.AllKeys() {
+		// key looks like: 'pagvpn.run', 'pagvpn.exec', 'splunk80.run', ...
+		definition := strings.SplitN(key, ".", 2)[0]
+		if !found[definition] {
+			found[definition] = true
+			names = append(names, definition)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ConfigStringList returns the value of a config which can be either a single string or a list of strings.
+// Differently from viper.GetStringSlice, a single string is not split on white spaces, which might be part of a path.
+func ConfigStringList(key string) []string {
+	switch value := viper.Get(key).(type) {
+	case nil:
+		return nil
+	case string:
+		if value == "" {
+			return nil
+		}
+		return []string{value}
+	default:
+		return viper.GetStringSlice(key)
+	}
+}
+
+/*
+SplitTarget splits a target provided on the command line into the definition name and the list of services.
+Services can be targeted only for compose definitions, using the syntax '<definition>:<service>[,<service>...]'.
+E.g.: 'composeexample:web,db' returns 'composeexample' and ['web', 'db'].
+*/
+func SplitTarget(target string) (definition string, services []string, err error) {
+	parts := strings.SplitN(target, ":", 2)
+	if len(parts) == 1 || parts[1] == "" {
+		return parts[0], nil, nil
+	}
+	if ConfigType(parts[0]) != CONFTYPECOMPOSE {
+		return parts[0], nil, fmt.Errorf("services can be targeted only for compose definitions, and '%s' is not one", parts[0])
+	}
+	return parts[0], strings.Split(parts[1], ","), nil
+}
+
+/*
+ResolveDefinitions translates the names provided on the command line into a list of definition names.
+A name starting with '@' references a group defined within 'settings.groups', which is expanded to its members.
+If 'all' is true, all the available definitions are returned.
+Names targeting services of compose definitions (see SplitTarget) are returned as they are.
+An error is returned if any of the names does not correspond to a definition or a group.
+*/
+func ResolveDefinitions(names []string, all bool) ([]string, error) {
+	if all {
+		return DefinitionNames(), nil
+	}
+	var definitions []string
+	for _, name := range names {
+		if strings.HasPrefix(name, "@") {
+			group := name[1:]
+			if !viper.IsSet("settings.groups." + group) {
+				return nil, fmt.Errorf("group '%s' is not defined within 'settings.groups'", group)
+			}
+			for _, member := range viper.GetStringSlice("settings.groups." + group) {
+				if definition, _, err := SplitTarget(member); err != nil {
+					return nil, fmt.Errorf("group '%s' references '%s'. %s", group, member, err)
+				} else if ConfigType(definition) == CONFTYPEUNKNOWN {
+					return nil, fmt.Errorf("group '%s' references '%s', which is not a valid definition", group, member)
+				}
+				if !IsIn(member, definitions) {
+					definitions = append(definitions, member)
+				}
+			}
+		} else if definition, _, err := SplitTarget(name); err != nil {
+			return nil, err
+		} else if ConfigType(definition) == CONFTYPEUNKNOWN {
+			return nil, fmt.Errorf("'%s' is not a valid definition", definition)
+		} else if !IsIn(name, definitions) {
+			definitions = append(definitions, name)
+		}
+	}
+	return definitions, nil
+}
+
+/*
+StateDir returns the folder where startainer keeps its local state, such as the time of the last image pulls.
+The folder is created if it does not exist yet.
+  - linux: $XDG_STATE_HOME/startainer or ~/.local/state/startainer
+  - others: <user config dir>/startainer, e.g. ~/Library/Application Support/startainer on OSX and %AppData%\startainer on windows
+*/
+func StateDir() (string, error) {
+	var base string
+	if runtime.GOOS == "linux" {
+		if base = os.Getenv("XDG_STATE_HOME"); base == "" {
+			home, err := homedir.Dir()
+			if err != nil {
+				return "", err
+			}
+			base = filepath.Join(home, ".local", "state")
+		}
+	} else {
+		var err error
+		if base, err = os.UserConfigDir(); err != nil {
+			return "", err
+		}
+	}
+	dir := filepath.Join(base, "startainer")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("impossible to create state folder '%s'. %s", dir, err)
+	}
+	return dir, nil
+}
+
+// readStateFile reads the JSON file 'name' from the state dir into 'v'.
+// A missing file is not an error, and leaves 'v' untouched.
+func readStateFile(name string, v interface{}) error {
+	dir, err := StateDir()
+	if err != nil {
+		return err
+	}
+	statefile := filepath.Join(dir, name)
+	content, err := ioutil.ReadFile(statefile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("impossible to read state file '%s'. %s", statefile, err)
+	}
+	return nil
+}
+
+// writeStateFile saves 'v' as the JSON file 'name' within the state dir
+func writeStateFile(name string, v interface{}) error {
+	dir, err := StateDir()
+	if err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, name), content, 0600)
+}
+
 /*
 ExpandPath is a function that takes a file path as a string and expands it to an absolute path.
 It returns the expanded path as a string, along with an error if any errors occur during the path expansion.
@@ -94,3 +253,15 @@ func IsIn(val string, list []string) bool {
 	}
 	return false
 }
+
+// Confirm asks the user a yes/no question on the terminal, and returns true only if the answer is yes.
+func Confirm(question string) bool {
+	if dryRun {
+		explain("would ask '%s', assuming yes", question)
+		return true
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}